@@ -0,0 +1,142 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+)
+
+// ACLConvention describes how a data domain maps onto Kafka resource names.
+// The prefixes are templates in which {domain} is replaced by the domain name.
+type ACLConvention struct {
+	TopicPrefix     string
+	GroupPrefix     string
+	TopicOperations []string
+	GroupOperations []string
+}
+
+var aclConvention = ACLConvention{
+	TopicPrefix:     "{domain}.",
+	GroupPrefix:     "{domain}.",
+	TopicOperations: []string{"READ", "WRITE", "DESCRIBE"},
+	GroupOperations: []string{"READ"},
+}
+
+// applyResult reports the generated ACLs added to a cluster and how many were already present.
+type applyResult struct {
+	Added    []ACL `json:"added"`
+	Existing int   `json:"existing"`
+}
+
+// handleGeneratedACLs handles /acls/generated and /acls/generated/apply
+func handleGeneratedACLs(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		writeError(w, http.StatusServiceUnavailable, "Identity database is not configured")
+		return
+	}
+	mappings, err := loadDomainIdentities(db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load domain identities: "+err.Error())
+		return
+	}
+	acls := generateACLs(mappings, aclConvention)
+
+	switch {
+	case r.URL.Path == "/acls/generated" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, acls)
+
+	case r.URL.Path == "/acls/generated/apply" && r.Method == "POST":
+		// Only add what the cluster is missing; ?dryRun=true reports without changing anything
+		cluster, err := requestCluster(r)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		current, err := listACLs(cluster)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list ACLs: "+err.Error())
+			return
+		}
+		missing := missingACLs(acls, current)
+		for i := range missing {
+			if err := normalizeACL(&missing[i]); err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "Invalid generated ACL: "+err.Error())
+				return
+			}
+		}
+		if r.URL.Query().Get("dryRun") != "true" && len(missing) > 0 {
+			if err := addACLs(cluster, missing); err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to apply ACLs: "+err.Error())
+				return
+			}
+			events.Publish(Event{Type: EventACLChanged, Cluster: cluster.Name, Data: map[string]interface{}{"action": "added", "acls": missing}})
+		}
+		writeJSON(w, http.StatusOK, applyResult{Added: missing, Existing: len(acls) - len(missing)})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// loadDomainIdentities returns the identities of every data domain
+func loadDomainIdentities(db *sql.DB) (map[string][]string, error) {
+	rows, err := db.Query("SELECT domain_name, identity FROM data_domain_identities ORDER BY domain_name, identity")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mappings := make(map[string][]string)
+	for rows.Next() {
+		var domain, identity string
+		if err := rows.Scan(&domain, &identity); err != nil {
+			return nil, err
+		}
+		mappings[domain] = append(mappings[domain], identity)
+	}
+	return mappings, rows.Err()
+}
+
+// generateACLs turns domain → identities mappings into prefixed topic and consumer-group ACLs
+func generateACLs(mappings map[string][]string, conv ACLConvention) []ACL {
+	var acls []ACL
+	for domain, identities := range mappings {
+		topicPrefix := strings.ReplaceAll(conv.TopicPrefix, "{domain}", domain)
+		groupPrefix := strings.ReplaceAll(conv.GroupPrefix, "{domain}", domain)
+		for _, identity := range identities {
+			principal := "User:" + identity
+			for _, op := range conv.TopicOperations {
+				acls = append(acls, prefixedACL(principal, op, "TOPIC", topicPrefix))
+			}
+			for _, op := range conv.GroupOperations {
+				acls = append(acls, prefixedACL(principal, op, "GROUP", groupPrefix))
+			}
+		}
+	}
+	sortACLs(acls)
+	return acls
+}
+
+func prefixedACL(principal, operation, resourceType, prefix string) ACL {
+	return ACL{
+		Principal:    principal,
+		Host:         "*",
+		Operation:    operation,
+		Permission:   "ALLOW",
+		ResourceType: resourceType,
+		ResourceName: prefix,
+		PatternType:  "PREFIXED",
+	}
+}
+
+// missingACLs returns the entries of want that are not present in have
+func missingACLs(want, have []ACL) []ACL {
+	present := make(map[string]struct{}, len(have))
+	for _, a := range have {
+		present[a.Key()] = struct{}{}
+	}
+	return filterACLs(want, func(a ACL) bool {
+		_, ok := present[a.Key()]
+		return !ok
+	})
+}
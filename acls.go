@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ACL is a single Kafka access control entry bound to a resource pattern.
+type ACL struct {
+	Principal    string `json:"principal" yaml:"principal"`       // e.g. User:svc-orders
+	Host         string `json:"host" yaml:"host"`                 // usually *
+	Operation    string `json:"operation" yaml:"operation"`       // READ, WRITE, DESCRIBE, ...
+	Permission   string `json:"permission" yaml:"permission"`     // ALLOW or DENY
+	ResourceType string `json:"resourceType" yaml:"resourceType"` // TOPIC, GROUP, CLUSTER, TRANSACTIONAL_ID
+	ResourceName string `json:"resourceName" yaml:"resourceName"`
+	PatternType  string `json:"patternType" yaml:"patternType"` // LITERAL or PREFIXED
+}
+
+// Key identifies the ACL for set comparisons.
+func (a ACL) Key() string {
+	return strings.Join([]string{a.Principal, a.Host, a.Operation, a.Permission, a.ResourceType, a.ResourceName, a.PatternType}, "|")
+}
+
+var (
+	// resourceLine matches "Current ACLs for resource `ResourcePattern(resourceType=TOPIC, name=foo, patternType=PREFIXED)`:"
+	resourceLine = regexp.MustCompile(`ResourcePattern\(resourceType=([A-Z_]+), name=([^,]+), patternType=([A-Z]+)\)`)
+	// entryLine matches "(principal=User:alice, host=*, operation=READ, permissionType=ALLOW)"
+	entryLine = regexp.MustCompile(`\(principal=([^,]+), host=([^,]+), operation=([A-Z_]+), permissionType=([A-Z]+)\)`)
+	// resourceName restricts topic, group and transactional ids passed to the pod shell.
+	resourceName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	// principalName restricts principals passed to the pod shell.
+	principalName = regexp.MustCompile(`^[A-Za-z]+:[A-Za-z0-9._@=*-]+$`)
+	// operationName matches kafka-acls.sh operation names such as READ or DESCRIBE_CONFIGS.
+	operationName = regexp.MustCompile(`^[A-Z_]+$`)
+)
+
+// resourceFlags maps ACL resource types to kafka-acls.sh options.
+var resourceFlags = map[string]string{
+	"TOPIC":            "--topic",
+	"GROUP":            "--group",
+	"TRANSACTIONAL_ID": "--transactional-id",
+	"CLUSTER":          "--cluster",
+}
+
+// handleACLs handles requests to the /acls endpoint
+func handleACLs(w http.ResponseWriter, r *http.Request) {
+	cluster, err := requestCluster(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		acls, err := listACLs(cluster)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list ACLs: "+err.Error())
+			return
+		}
+		if p := r.URL.Query().Get("principal"); p != "" {
+			acls = filterACLs(acls, func(a ACL) bool { return a.Principal == p })
+		}
+		writeJSON(w, http.StatusOK, acls)
+
+	case "POST", "DELETE":
+		// Add or remove ACLs (expecting a JSON array of ACL objects)
+		var acls []ACL
+		if err := json.NewDecoder(r.Body).Decode(&acls); err != nil || len(acls) == 0 {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		for i := range acls {
+			if err := normalizeACL(&acls[i]); err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid ACL: "+err.Error())
+				return
+			}
+		}
+		if r.Method == "POST" {
+			err = addACLs(cluster, acls)
+		} else {
+			err = removeACLs(cluster, acls)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to update ACLs: "+err.Error())
+			return
+		}
+		action, status := "removed", http.StatusOK
+		if r.Method == "POST" {
+			action, status = "added", http.StatusCreated
+		}
+		events.Publish(Event{Type: EventACLChanged, Cluster: cluster.Name, Data: map[string]interface{}{"action": action, "acls": acls}})
+		writeJSON(w, status, acls)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// normalizeACL fills defaults, upper-cases enums and rejects values unsafe for the pod shell
+func normalizeACL(a *ACL) error {
+	if a.Host == "" {
+		a.Host = "*"
+	}
+	if a.Permission == "" {
+		a.Permission = "ALLOW"
+	}
+	if a.PatternType == "" {
+		a.PatternType = "LITERAL"
+	}
+	a.Operation = strings.ToUpper(a.Operation)
+	a.Permission = strings.ToUpper(a.Permission)
+	a.ResourceType = strings.ToUpper(a.ResourceType)
+	a.PatternType = strings.ToUpper(a.PatternType)
+
+	if a.ResourceType == "CLUSTER" {
+		a.ResourceName = "kafka-cluster"
+	}
+	switch {
+	case !principalName.MatchString(a.Principal):
+		return fmt.Errorf("invalid principal %q", a.Principal)
+	case resourceFlags[a.ResourceType] == "":
+		return fmt.Errorf("invalid resource type %q", a.ResourceType)
+	case !resourceName.MatchString(a.ResourceName):
+		return fmt.Errorf("invalid resource name %q", a.ResourceName)
+	case a.Permission != "ALLOW" && a.Permission != "DENY":
+		return fmt.Errorf("invalid permission %q", a.Permission)
+	case a.PatternType != "LITERAL" && a.PatternType != "PREFIXED":
+		return fmt.Errorf("invalid pattern type %q", a.PatternType)
+	case !operationName.MatchString(a.Operation):
+		return fmt.Errorf("invalid operation %q", a.Operation)
+	case a.Host != "*" && !resourceName.MatchString(a.Host):
+		return fmt.Errorf("invalid host %q", a.Host)
+	}
+	return nil
+}
+
+// listACLs runs kafka-acls.sh --list in the pod and parses its output
+func listACLs(c *Cluster) ([]ACL, error) {
+	output, err := execInPod(c, "kafka-acls.sh "+bootstrapArg+" --list")
+	if err != nil {
+		return nil, err
+	}
+	return parseACLs(output), nil
+}
+
+// parseACLs parses kafka-acls.sh --list output into ACL entries
+func parseACLs(output string) []ACL {
+	var acls []ACL
+	var current []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := resourceLine.FindStringSubmatch(line); m != nil {
+			current = m
+			continue
+		}
+		if m := entryLine.FindStringSubmatch(line); m != nil && current != nil {
+			acls = append(acls, ACL{
+				Principal:    m[1],
+				Host:         m[2],
+				Operation:    m[3],
+				Permission:   m[4],
+				ResourceType: current[1],
+				ResourceName: current[2],
+				PatternType:  current[3],
+			})
+		}
+	}
+	sortACLs(acls)
+	return acls
+}
+
+// addACLs adds the given ACLs, batching operations that share a principal and resource
+func addACLs(c *Cluster, acls []ACL) error {
+	return alterACLs(c, "--add", acls)
+}
+
+// removeACLs removes the given ACLs, batching operations that share a principal and resource
+func removeACLs(c *Cluster, acls []ACL) error {
+	return alterACLs(c, "--remove --force", acls)
+}
+
+func alterACLs(c *Cluster, action string, acls []ACL) error {
+	type batchKey struct{ principal, host, permission, resourceType, resourceName, patternType string }
+	batches := map[batchKey][]string{}
+	var order []batchKey
+	for _, a := range acls {
+		k := batchKey{a.Principal, a.Host, a.Permission, a.ResourceType, a.ResourceName, a.PatternType}
+		if _, ok := batches[k]; !ok {
+			order = append(order, k)
+		}
+		batches[k] = append(batches[k], a.Operation)
+	}
+
+	for _, k := range order {
+		cmd := fmt.Sprintf("kafka-acls.sh %s %s --%s-principal '%s' --%s-host '%s'",
+			bootstrapArg, action, strings.ToLower(k.permission), k.principal, strings.ToLower(k.permission), k.host)
+		for _, op := range batches[k] {
+			cmd += " --operation " + op
+		}
+		if k.resourceType == "CLUSTER" {
+			cmd += " --cluster"
+		} else {
+			cmd += fmt.Sprintf(" %s %s --resource-pattern-type %s", resourceFlags[k.resourceType], k.resourceName, strings.ToLower(k.patternType))
+		}
+		if _, err := execInPod(c, cmd); err != nil {
+			return fmt.Errorf("%s %s on %s %s: %w", action, k.principal, k.resourceType, k.resourceName, err)
+		}
+	}
+	return nil
+}
+
+// filterACLs returns the ACLs for which keep returns true
+func filterACLs(acls []ACL, keep func(ACL) bool) []ACL {
+	out := []ACL{}
+	for _, a := range acls {
+		if keep(a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// sortACLs orders ACLs by principal, resource and operation so output is stable
+func sortACLs(acls []ACL) {
+	sort.Slice(acls, func(i, j int) bool { return acls[i].Key() < acls[j].Key() })
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type clientKey struct{}
+
+// apiTokens maps bearer tokens to client names. When empty, the API is open.
+var apiTokens = map[string]string{}
+
+// loadTokens reads a YAML file of the form "tokens: {<token>: <client name>}".
+func loadTokens(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var tf struct {
+		Tokens map[string]string `yaml:"tokens"`
+	}
+	if err := yaml.Unmarshal(data, &tf); err != nil {
+		return err
+	}
+	apiTokens = tf.Tokens
+	return nil
+}
+
+// publicPath reports whether a path is served without a token: the OpenAPI document, so it
+// can double as a readiness probe, and the static UI, which asks the user for a token itself.
+func publicPath(path string) bool {
+	return path == "/openapi.json" || path == "/" || strings.HasPrefix(path, "/ui/")
+}
+
+// withAuth rejects requests without a known bearer token and records the client name on the request context.
+// Failed attempts are counted per remote address in authFailures; once an address has used up its
+// allowance every request from it gets 429 before its token is looked at, so tokens cannot be guessed
+// at the speed of the network.
+func withAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(apiTokens) == 0 || publicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		addr := remoteHost(r)
+		if blocked, delay := authFailures.exhausted(addr); blocked {
+			rateLimitedTotal.WithLabelValues("unauthenticated").Inc()
+			tooManyRequests(w, delay, "Too many failed authentication attempts from "+addr)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		client := ""
+		if ok {
+			for t, name := range apiTokens {
+				if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+					client = name
+				}
+			}
+		}
+		if client == "" {
+			authFailures.allow(addr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKey{}, client)))
+	})
+}
+
+// requestClient returns the authenticated client name, or the remote address when auth is disabled.
+func requestClient(r *http.Request) string {
+	if client, ok := r.Context().Value(clientKey{}).(string); ok {
+		return client
+	}
+	return remoteHost(r)
+}
+
+// remoteHost returns the remote address of a request without its port
+func remoteHost(r *http.Request) string {
+	host := r.RemoteAddr
+	if i := strings.LastIndex(host, ":"); i > 0 {
+		host = host[:i]
+	}
+	return host
+}
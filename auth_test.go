@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestFailedAuthIsRateLimited checks that an address guessing tokens is throttled before its
+// tokens are checked, while other addresses are unaffected.
+func TestFailedAuthIsRateLimited(t *testing.T) {
+	savedTokens, savedFailures := apiTokens, authFailures
+	apiTokens = map[string]string{"good-token": "ci"}
+	authFailures = &clientLimiter{rate: 0.1, burst: 3, limiters: map[string]*clientBucket{}}
+	t.Cleanup(func() { apiTokens, authFailures = savedTokens, savedFailures })
+
+	h := withAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+	do := func(addr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/topics", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := do("10.0.0.1:4000", "guess"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status = %d, want 401", i+1, rec.Code)
+		}
+	}
+	rec := do("10.0.0.1:4001", "good-token")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("after the failure burst: status = %d, Retry-After = %q; want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := do("10.0.0.2:4000", "good-token"); rec.Code != http.StatusNoContent {
+		t.Errorf("other address: status = %d, want 204", rec.Code)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// archiveVersion is bumped whenever the Archive layout changes incompatibly.
+const archiveVersion = 1
+
+// Archive is a snapshot of a cluster's topics, ACLs and quotas.
+type Archive struct {
+	Version   int         `json:"version" yaml:"version"`
+	CreatedAt time.Time   `json:"createdAt" yaml:"createdAt"`
+	Cluster   string      `json:"cluster" yaml:"cluster"`
+	Topics    []TopicSpec `json:"topics" yaml:"topics"`
+	ACLs      []ACL       `json:"acls" yaml:"acls"`
+	Quotas    struct {
+		Users   []kafkaUser `json:"users" yaml:"users"`
+		Clients []kafkaUser `json:"clients" yaml:"clients"`
+	} `json:"quotas" yaml:"quotas"`
+}
+
+// snapshotCluster collects the archive contents from a cluster
+func snapshotCluster(c *Cluster) (*Archive, error) {
+	archive := &Archive{Version: archiveVersion, CreatedAt: time.Now().UTC(), Cluster: c.Name}
+
+	var err error
+	if archive.Topics, err = describeTopics(c); err != nil {
+		return nil, fmt.Errorf("failed to describe topics: %w", err)
+	}
+	if archive.ACLs, err = listACLs(c); err != nil {
+		return nil, fmt.Errorf("failed to list ACLs: %w", err)
+	}
+	if archive.Quotas.Users, err = describeEntities(c, "users"); err != nil {
+		return nil, fmt.Errorf("failed to describe user quotas: %w", err)
+	}
+	if archive.Quotas.Clients, err = describeEntities(c, "clients"); err != nil {
+		return nil, fmt.Errorf("failed to describe client quotas: %w", err)
+	}
+	// SCRAM credentials are stored as salted hashes and cannot be restored; keep quotas only
+	archive.Quotas.Users = withQuotas(archive.Quotas.Users)
+	archive.Quotas.Clients = withQuotas(archive.Quotas.Clients)
+	return archive, nil
+}
+
+// restoreArchive creates the topics, ACLs and quotas from the archive that are missing on the cluster
+// and returns a line per item it created (or would create when dryRun is set)
+func restoreArchive(c *Cluster, archive *Archive, dryRun bool) ([]string, error) {
+	if archive.Version != archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", archive.Version)
+	}
+	var actions []string
+
+	existing, err := describeTopics(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe topics: %w", err)
+	}
+	present := map[string]bool{}
+	for _, t := range existing {
+		present[t.Name] = true
+	}
+	for _, t := range archive.Topics {
+		if present[t.Name] {
+			continue
+		}
+		actions = append(actions, fmt.Sprintf("create topic %s (partitions=%d, replicationFactor=%d)", t.Name, t.Partitions, t.ReplicationFactor))
+		if !dryRun {
+			if err := createTopicInPod(c, t); err != nil {
+				return actions, fmt.Errorf("failed to create topic %s: %w", t.Name, err)
+			}
+			events.Publish(Event{Type: EventTopicCreated, Cluster: c.Name, Data: t})
+		}
+	}
+
+	current, err := listACLs(c)
+	if err != nil {
+		return actions, fmt.Errorf("failed to list ACLs: %w", err)
+	}
+	missing := missingACLs(archive.ACLs, current)
+	for i := range missing {
+		if err := normalizeACL(&missing[i]); err != nil {
+			return actions, err
+		}
+		actions = append(actions, fmt.Sprintf("add ACL %s %s %s", missing[i].Principal, missing[i].Operation, aclResource(missing[i])))
+	}
+	if !dryRun && len(missing) > 0 {
+		if err := addACLs(c, missing); err != nil {
+			return actions, fmt.Errorf("failed to add ACLs: %w", err)
+		}
+		events.Publish(Event{Type: EventACLChanged, Cluster: c.Name, Data: map[string]interface{}{"action": "added", "acls": missing}})
+	}
+
+	for _, q := range []struct {
+		entityType string
+		want       []kafkaUser
+	}{{"users", archive.Quotas.Users}, {"clients", archive.Quotas.Clients}} {
+		entityType, want := q.entityType, q.want
+		have, err := describeEntities(c, entityType)
+		if err != nil {
+			return actions, fmt.Errorf("failed to describe %s quotas: %w", entityType, err)
+		}
+		haveQuotas := map[string]map[string]float64{}
+		for _, e := range have {
+			haveQuotas[e.Name] = e.Quotas
+		}
+		for _, e := range want {
+			var configs []string
+			for key, value := range e.Quotas {
+				if _, ok := haveQuotas[e.Name][key]; !ok {
+					configs = append(configs, key+"="+strconv.FormatFloat(value, 'f', -1, 64))
+				}
+			}
+			if len(configs) == 0 {
+				continue
+			}
+			if !entityName.MatchString(e.Name) {
+				return actions, fmt.Errorf("invalid %s entity %q", entityType, e.Name)
+			}
+			sort.Strings(configs)
+			actions = append(actions, fmt.Sprintf("set %s quota %s %s", entityType, e.Name, strings.Join(configs, ",")))
+			if !dryRun {
+				if err := alterEntityConfig(c, entityType, e.Name, "--add-config "+strings.Join(configs, ",")); err != nil {
+					return actions, fmt.Errorf("failed to set quota for %s: %w", e.Name, err)
+				}
+			}
+		}
+	}
+	return actions, nil
+}
+
+// withQuotas drops entities that only carry credentials
+func withQuotas(entities []kafkaUser) []kafkaUser {
+	out := []kafkaUser{}
+	for _, e := range entities {
+		if len(e.Quotas) > 0 {
+			out = append(out, kafkaUser{Name: e.Name, Quotas: e.Quotas})
+		}
+	}
+	return out
+}
+
+// writeArchive saves the archive as YAML for .yaml/.yml files and JSON otherwise
+func writeArchive(file string, archive *Archive) error {
+	var data []byte
+	var err error
+	if isYAMLFile(file) {
+		data, err = yaml.Marshal(archive)
+	} else {
+		data, err = json.MarshalIndent(archive, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o600)
+}
+
+// readArchive loads an archive written by writeArchive
+func readArchive(file string) (*Archive, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{}
+	if isYAMLFile(file) {
+		err = yaml.Unmarshal(data, archive)
+	} else {
+		err = json.Unmarshal(data, archive)
+	}
+	return archive, err
+}
+
+func isYAMLFile(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// runBackup implements the "backup" subcommand
+func runBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	clusterName := fs.String("cluster", "default", "Cluster to snapshot")
+	out := fs.String("o", "", "Archive file to write (.json, .yaml or .yml); defaults to <cluster>-<timestamp>.json")
+	fs.Parse(args)
+
+	cluster, err := lookupCluster(*clusterName)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	archive, err := snapshotCluster(cluster)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	file := *out
+	if file == "" {
+		file = fmt.Sprintf("%s-%s.json", cluster.Name, archive.CreatedAt.Format("20060102T150405Z"))
+	}
+	if err := writeArchive(file, archive); err != nil {
+		log.Print(err)
+		return 1
+	}
+	fmt.Printf("Wrote %d topics, %d ACLs and %d quotas to %s\n",
+		len(archive.Topics), len(archive.ACLs), len(archive.Quotas.Users)+len(archive.Quotas.Clients), file)
+	return 0
+}
+
+// runRestore implements the "restore" subcommand
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	clusterName := fs.String("cluster", "default", "Cluster to restore into")
+	in := fs.String("f", "", "Archive file written by backup")
+	dryRun := fs.Bool("dry-run", false, "Print what would be created without changing the cluster")
+	fs.Parse(args)
+
+	if *in == "" {
+		log.Print("restore requires -f")
+		return 1
+	}
+	cluster, err := lookupCluster(*clusterName)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	archive, err := readArchive(*in)
+	if err != nil {
+		log.Printf("Failed to read archive: %v", err)
+		return 1
+	}
+	actions, err := restoreArchive(cluster, archive, *dryRun)
+	for _, a := range actions {
+		fmt.Println(a)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(actions) == 0 {
+		fmt.Printf("Cluster %s already contains everything in %s\n", cluster.Name, *in)
+	}
+	return 0
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CatalogEntry records who owns a topic and what it holds. Entries live in the
+// topic_catalog table, keyed by cluster and topic name.
+type CatalogEntry struct {
+	Domain             string     `json:"domain,omitempty" yaml:"domain,omitempty"` // must exist in data_domains
+	Owner              string     `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Description        string     `json:"description,omitempty" yaml:"description,omitempty"`
+	Classification     string     `json:"classification,omitempty" yaml:"classification,omitempty"` // public, internal, confidential or restricted
+	RetentionRationale string     `json:"retentionRationale,omitempty" yaml:"retentionRationale,omitempty"`
+	Contact            string     `json:"contact,omitempty" yaml:"contact,omitempty"`
+	UpdatedAt          *time.Time `json:"updatedAt,omitempty" yaml:"-"`
+}
+
+// CatalogRecord is a catalog entry together with the topic it describes.
+type CatalogRecord struct {
+	Topic string `json:"topic"`
+	CatalogEntry
+}
+
+// CatalogReport lists the topics on a cluster whose ownership is unknown.
+type CatalogReport struct {
+	Cluster      string   `json:"cluster"`
+	Uncatalogued []string `json:"uncatalogued"` // topics with no catalog entry
+	Unowned      []string `json:"unowned"`      // topics whose entry has no owner or contact
+}
+
+var classifications = []string{"public", "internal", "confidential", "restricted"}
+
+// validate checks the fields a user sets when editing an entry
+func (e *CatalogEntry) validate(db *sql.DB) error {
+	if e.Owner == "" || e.Contact == "" {
+		return fmt.Errorf("owner and contact are required")
+	}
+	if e.Classification != "" && !containsString(classifications, e.Classification) {
+		return fmt.Errorf("classification must be one of %s", strings.Join(classifications, ", "))
+	}
+	if e.Domain != "" {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM data_domains WHERE domain_name = $1)", e.Domain).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("data domain %q does not exist", e.Domain)
+		}
+	}
+	return nil
+}
+
+// domainOf returns the domain segment of a topic name under the naming policy, or ""
+func (p *NamingPolicy) domainOf(name string) string {
+	if p == nil || p.format == nil {
+		return ""
+	}
+	i := p.format.SubexpIndex("domain")
+	m := p.format.FindStringSubmatch(name)
+	if i < 0 || m == nil {
+		return ""
+	}
+	return m[i]
+}
+
+// recordTopicCreated stores the catalog entry of a newly created topic. Topics created without
+// one get an entry with no owner, so they show up in the unowned report. The domain defaults to
+// the domain segment of the name and is left empty when it is not in data_domains.
+func recordTopicCreated(db *sql.DB, c *Cluster, spec TopicSpec) error {
+	e := CatalogEntry{}
+	if spec.Catalog != nil {
+		e = *spec.Catalog
+	}
+	if e.Domain == "" {
+		e.Domain = namingPolicy.domainOf(spec.Name)
+	}
+	_, err := db.Exec(`INSERT INTO topic_catalog
+		(cluster, topic_name, domain_name, owner, description, classification, retention_rationale, contact)
+		VALUES ($1, $2, (SELECT domain_name FROM data_domains WHERE domain_name = $3), $4, $5, $6, $7, $8)
+		ON CONFLICT (cluster, topic_name) DO NOTHING`,
+		c.Name, spec.Name, e.Domain, e.Owner, e.Description, e.Classification, e.RetentionRationale, e.Contact)
+	return err
+}
+
+// saveCatalogEntry creates or replaces the catalog entry of a topic
+func saveCatalogEntry(db *sql.DB, c *Cluster, topic string, e CatalogEntry) error {
+	_, err := db.Exec(`INSERT INTO topic_catalog
+		(cluster, topic_name, domain_name, owner, description, classification, retention_rationale, contact)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
+		ON CONFLICT (cluster, topic_name) DO UPDATE SET
+			domain_name = EXCLUDED.domain_name, owner = EXCLUDED.owner, description = EXCLUDED.description,
+			classification = EXCLUDED.classification, retention_rationale = EXCLUDED.retention_rationale,
+			contact = EXCLUDED.contact, updated_at = CURRENT_TIMESTAMP`,
+		c.Name, topic, e.Domain, e.Owner, e.Description, e.Classification, e.RetentionRationale, e.Contact)
+	return err
+}
+
+// deleteCatalogEntry removes the catalog entry of a topic, if any
+func deleteCatalogEntry(db *sql.DB, c *Cluster, topic string) error {
+	_, err := db.Exec("DELETE FROM topic_catalog WHERE cluster = $1 AND topic_name = $2", c.Name, topic)
+	return err
+}
+
+// loadCatalog returns the catalog entries of a cluster, optionally limited to one topic
+func loadCatalog(db *sql.DB, c *Cluster, topic string) ([]CatalogRecord, error) {
+	rows, err := db.Query(`SELECT topic_name, COALESCE(domain_name, ''), owner, description, classification,
+			retention_rationale, contact, updated_at
+		FROM topic_catalog WHERE cluster = $1 AND ($2 = '' OR topic_name = $2) ORDER BY topic_name`, c.Name, topic)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []CatalogRecord{}
+	for rows.Next() {
+		var r CatalogRecord
+		if err := rows.Scan(&r.Topic, &r.Domain, &r.Owner, &r.Description, &r.Classification,
+			&r.RetentionRationale, &r.Contact, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// catalogReport compares the topics on the cluster with the catalog
+func catalogReport(db *sql.DB, c *Cluster) (*CatalogReport, error) {
+	topics, err := listTopicsInPod(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+	records, err := loadCatalog(db, c, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load catalog: %w", err)
+	}
+	entries := map[string]CatalogRecord{}
+	for _, r := range records {
+		entries[r.Topic] = r
+	}
+
+	report := &CatalogReport{Cluster: c.Name, Uncatalogued: []string{}, Unowned: []string{}}
+	for _, t := range topics {
+		// Internal topics such as __consumer_offsets are owned by Kafka itself
+		if t == "" || strings.HasPrefix(t, "__") {
+			continue
+		}
+		e, ok := entries[t]
+		switch {
+		case !ok:
+			report.Uncatalogued = append(report.Uncatalogued, t)
+		case e.Owner == "" || e.Contact == "":
+			report.Unowned = append(report.Unowned, t)
+		}
+	}
+	sort.Strings(report.Uncatalogued)
+	sort.Strings(report.Unowned)
+	return report, nil
+}
+
+// handleCatalog handles /catalog/topics, /catalog/topics/{name} and /catalog/uncatalogued
+func handleCatalog(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		writeError(w, http.StatusServiceUnavailable, "Identity database is not configured")
+		return
+	}
+	cluster, err := requestCluster(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	name, isTopic := strings.CutPrefix(r.URL.Path, "/catalog/topics/")
+	if isTopic && !resourceName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "Invalid topic name")
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/catalog/topics" && r.Method == "GET":
+		records, err := loadCatalog(db, cluster, "")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to load catalog: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, records)
+
+	case r.URL.Path == "/catalog/uncatalogued" && r.Method == "GET":
+		report, err := catalogReport(db, cluster)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+
+	case isTopic && r.Method == "GET":
+		records, err := loadCatalog(db, cluster, name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to load catalog: "+err.Error())
+			return
+		}
+		if len(records) == 0 {
+			writeError(w, http.StatusNotFound, "Topic "+name+" is not catalogued")
+			return
+		}
+		writeJSON(w, http.StatusOK, records[0])
+
+	case isTopic && r.Method == "PUT":
+		// Replace the entry (expecting JSON payload with owner and contact and optional
+		// domain, description, classification and retentionRationale)
+		var entry CatalogEntry
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if err := entry.validate(db); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid catalog entry: "+err.Error())
+			return
+		}
+		// the only catalog request that execs, so the route is not exec-limited as a whole
+		release, ok := acquireExecSlot(w, r, cluster)
+		if !ok {
+			return
+		}
+		topic, err := describeTopicInPod(cluster, name)
+		release()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to describe topic: "+err.Error())
+			return
+		}
+		if topic == nil {
+			writeError(w, http.StatusNotFound, "Topic "+name+" not found")
+			return
+		}
+		if err := saveCatalogEntry(db, cluster, name, entry); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to save catalog entry: "+err.Error())
+			return
+		}
+		now := time.Now().UTC()
+		entry.UpdatedAt = &now
+		writeJSON(w, http.StatusOK, CatalogRecord{Topic: name, CatalogEntry: entry})
+
+	case isTopic && r.Method == "DELETE":
+		if err := deleteCatalogEntry(db, cluster, name); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to delete catalog entry: "+err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// logCatalogError reports a catalog update that failed after the topic itself was changed;
+// the topic then shows up in the uncatalogued report
+func logCatalogError(topic string, err error) {
+	if err != nil {
+		log.Printf("Failed to update catalog entry of topic %s: %v", topic, err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Cluster describes one Kafka cluster the service can administer.
+type Cluster struct {
+	Name       string `yaml:"name"`       // Name used in URLs, e.g. /connect/{cluster}/...
+	Namespace  string `yaml:"namespace"`  // Namespace of the Kafka broker pod
+	Pod        string `yaml:"pod"`        // Broker pod used for kafka-*.sh exec
+	ConnectURL string `yaml:"connectURL"` // Kafka Connect REST endpoint, e.g. http://kafka-connect:8083
+}
+
+// clusterFile is the layout of the -clusters YAML file.
+type clusterFile struct {
+	Clusters []Cluster `yaml:"clusters"`
+}
+
+var clusters = map[string]*Cluster{}
+
+// loadClusters reads the cluster definitions from a YAML file.
+func loadClusters(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var cf clusterFile
+	if err := yaml.Unmarshal(data, &cf); err != nil {
+		return err
+	}
+	for i := range cf.Clusters {
+		c := &cf.Clusters[i]
+		if c.Name == "" {
+			return fmt.Errorf("cluster %d has no name", i)
+		}
+		clusters[c.Name] = c
+	}
+	return nil
+}
+
+// lookupCluster returns the named cluster or an error if it is not configured.
+func lookupCluster(name string) (*Cluster, error) {
+	c, ok := clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster %q", name)
+	}
+	return c, nil
+}
+
+// clusterNames returns the configured cluster names in sorted order.
+func clusterNames() []string {
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// requestCluster resolves the ?cluster= query parameter, falling back to the default cluster.
+func requestCluster(r *http.Request) (*Cluster, error) {
+	name := r.URL.Query().Get("cluster")
+	if name == "" {
+		name = "default"
+	}
+	return lookupCluster(name)
+}
+
+// handleClusters handles requests to the /clusters endpoint
+func handleClusters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, clusterNames())
+}
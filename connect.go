@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var connectClient = &http.Client{Timeout: 30 * time.Second}
+
+// secretKey matches connector config keys whose values must not leave the service.
+var secretKey = regexp.MustCompile(`(?i)(password|secret|token|credentials|jaas\.config|api\.key|private\.key)`)
+
+const redacted = "********"
+
+// errRedactedValue is returned when a config sent back still contains a redacted placeholder
+// for a key the stored connector does not have.
+var errRedactedValue = errors.New("redacted value")
+
+// connectError is a non-2xx response from the Kafka Connect REST API.
+type connectError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *connectError) Error() string {
+	return fmt.Sprintf("connect returned %s: %s", e.Status, e.Message)
+}
+
+// isNotFound reports whether Kafka Connect answered 404
+func isNotFound(err error) bool {
+	var ce *connectError
+	return errors.As(err, &ce) && ce.StatusCode == http.StatusNotFound
+}
+
+// connectorRequest is the body accepted when creating or updating a connector.
+type connectorRequest struct {
+	Name   string            `json:"name" yaml:"name"`
+	Config map[string]string `json:"config" yaml:"config"`
+}
+
+// connectorView is a connector as returned to API clients.
+type connectorView struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type,omitempty"`
+	Config map[string]string `json:"config,omitempty"`
+	Status json.RawMessage   `json:"status,omitempty"`
+}
+
+// handleConnect handles requests under /connect/{cluster}/connectors
+func handleConnect(w http.ResponseWriter, r *http.Request) {
+	// /connect/{cluster}/connectors[/{name}[/{action}|/tasks/{id}/restart]]
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/connect/"), "/"), "/")
+	if len(parts) < 2 || parts[1] != "connectors" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	cluster, err := lookupCluster(parts[0])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if cluster.ConnectURL == "" {
+		writeError(w, http.StatusNotFound, "Kafka Connect is not configured for cluster "+cluster.Name)
+		return
+	}
+	parts = parts[2:]
+	// Path segments are decoded by net/http; escape them again before building Connect URLs
+	var connectorPath string
+	if len(parts) > 0 {
+		connectorPath = "/connectors/" + url.PathEscape(parts[0])
+	}
+
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		connectors, err := listConnectors(cluster)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "Failed to list connectors: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, connectors)
+
+	case len(parts) == 0 && r.Method == "POST", len(parts) == 1 && r.Method == "PUT":
+		req, err := decodeConnectorRequest(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+		if len(parts) == 1 {
+			req.Name = parts[0]
+		}
+		if req.Name == "" || len(req.Config) == 0 {
+			writeError(w, http.StatusBadRequest, "Invalid request body: name and config are required")
+			return
+		}
+		created, err := putConnector(cluster, req)
+		if errors.Is(err, errRedactedValue) {
+			writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "Failed to save connector: "+err.Error())
+			return
+		}
+		events.Publish(Event{Type: EventConnectChanged, Cluster: cluster.Name, Data: map[string]string{"connector": req.Name, "action": "saved"}})
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, connectorView{Name: req.Name, Config: redactConfig(req.Config)})
+
+	case len(parts) == 1 && r.Method == "GET":
+		connector, err := getConnector(cluster, parts[0])
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "Connector "+parts[0]+" not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "Failed to get connector: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, connector)
+
+	case len(parts) == 1 && r.Method == "DELETE":
+		if err := connectCall(cluster, "DELETE", connectorPath, nil, nil); err != nil {
+			writeError(w, http.StatusBadGateway, "Failed to delete connector: "+err.Error())
+			return
+		}
+		events.Publish(Event{Type: EventConnectChanged, Cluster: cluster.Name, Data: map[string]string{"connector": parts[0], "action": "deleted"}})
+		w.WriteHeader(http.StatusNoContent)
+
+	case len(parts) == 2 && r.Method == "POST":
+		var err error
+		switch parts[1] {
+		case "pause", "resume":
+			err = connectCall(cluster, "PUT", connectorPath+"/"+parts[1], nil, nil)
+		case "restart":
+			err = connectCall(cluster, "POST", connectorPath+"/restart?includeTasks=true", nil, nil)
+		default:
+			writeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "Failed to "+parts[1]+" connector: "+err.Error())
+			return
+		}
+		events.Publish(Event{Type: EventConnectChanged, Cluster: cluster.Name, Data: map[string]string{"connector": parts[0], "action": parts[1]}})
+		w.WriteHeader(http.StatusAccepted)
+
+	case len(parts) == 4 && parts[1] == "tasks" && parts[3] == "restart" && r.Method == "POST":
+		task, err := strconv.Atoi(parts[2])
+		if err != nil || task < 0 {
+			writeError(w, http.StatusBadRequest, "Task id must be a non-negative number")
+			return
+		}
+		if err := connectCall(cluster, "POST", connectorPath+"/tasks/"+strconv.Itoa(task)+"/restart", nil, nil); err != nil {
+			writeError(w, http.StatusBadGateway, "Failed to restart task: "+err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// decodeConnectorRequest reads a connector definition as JSON or YAML depending on Content-Type.
+func decodeConnectorRequest(r *http.Request) (connectorRequest, error) {
+	var req connectorRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return req, err
+	}
+	ct := r.Header.Get("Content-Type")
+	if strings.Contains(ct, "yaml") {
+		err = yaml.Unmarshal(body, &req)
+	} else {
+		err = json.Unmarshal(body, &req)
+	}
+	return req, err
+}
+
+// listConnectors returns every connector on the cluster with its status and redacted config.
+func listConnectors(c *Cluster) ([]connectorView, error) {
+	var expanded map[string]struct {
+		Info struct {
+			Type   string            `json:"type"`
+			Config map[string]string `json:"config"`
+		} `json:"info"`
+		Status json.RawMessage `json:"status"`
+	}
+	if err := connectCall(c, "GET", "/connectors?expand=info&expand=status", nil, &expanded); err != nil {
+		return nil, err
+	}
+	connectors := make([]connectorView, 0, len(expanded))
+	for name, e := range expanded {
+		connectors = append(connectors, connectorView{
+			Name:   name,
+			Type:   e.Info.Type,
+			Config: redactConfig(e.Info.Config),
+			Status: e.Status,
+		})
+	}
+	return connectors, nil
+}
+
+// getConnector returns a single connector with its status and redacted config.
+func getConnector(c *Cluster, name string) (*connectorView, error) {
+	view := &connectorView{Name: name}
+	path := "/connectors/" + url.PathEscape(name)
+	if err := connectCall(c, "GET", path+"/config", nil, &view.Config); err != nil {
+		return nil, err
+	}
+	view.Config = redactConfig(view.Config)
+	if err := connectCall(c, "GET", path+"/status", nil, &view.Status); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// putConnector creates or updates a connector and reports whether it was newly created.
+// Values still redacted from an earlier GET keep the stored secret instead of overwriting it.
+func putConnector(c *Cluster, req connectorRequest) (bool, error) {
+	path := "/connectors/" + url.PathEscape(req.Name) + "/config"
+	var existing map[string]string
+	created := false
+	if err := connectCall(c, "GET", path, nil, &existing); isNotFound(err) {
+		created = true
+	} else if err != nil {
+		return false, err
+	}
+
+	config := make(map[string]string, len(req.Config))
+	for k, v := range req.Config {
+		if v == redacted {
+			stored, ok := existing[k]
+			if !ok {
+				return false, fmt.Errorf("%w: %s is %q; send the real value", errRedactedValue, k, redacted)
+			}
+			v = stored
+		}
+		config[k] = v
+	}
+	if err := connectCall(c, "PUT", path, config, nil); err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// connectCall performs a request against the Kafka Connect REST API of the cluster.
+func connectCall(c *Cluster, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(c.ConnectURL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := connectClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var connectErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&connectErr)
+		return &connectError{StatusCode: resp.StatusCode, Status: resp.Status, Message: connectErr.Message}
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// redactConfig masks values of keys that look like credentials.
+func redactConfig(config map[string]string) map[string]string {
+	if config == nil {
+		return nil
+	}
+	out := make(map[string]string, len(config))
+	for k, v := range config {
+		if secretKey.MatchString(k) {
+			v = redacted
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -4,8 +4,11 @@ database:
   host: "localhost"
   port: 5432
   user: "username"
-  password: "password"
+  password: "password"  # leave empty to use PGPASSFILE / ~/.pgpass
   dbname: "your_database"
+  sslmode: "verify-full"  # disable, require, verify-ca or verify-full; defaults to disable
+  search_path: "identity"  # optional schema search path
+  passfile: "/path/to/.pgpass"  # optional, sets PGPASSFILE when it is not already set
 
 ####################################
 
@@ -14,35 +17,77 @@ package dbutils
 
 import (
 	"os"
+	"strconv"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
 	Database struct {
-		Driver   string `yaml:"driver"`
-		Host     string `yaml:"host"`
-		Port     int    `yaml:"port"`
-		User     string `yaml:"user"`
-		Password string `yaml:"password"`
-		DBName   string `yaml:"dbname"`
+		Driver     string `yaml:"driver"`
+		Host       string `yaml:"host"`
+		Port       int    `yaml:"port"`
+		User       string `yaml:"user"`
+		Password   string `yaml:"password"`
+		DBName     string `yaml:"dbname"`
+		SSLMode    string `yaml:"sslmode"`
+		SearchPath string `yaml:"search_path"`
+		PassFile   string `yaml:"passfile"`
 	} `yaml:"database"`
 }
 
-// LoadConfig loads the database configuration from a YAML file.
+// LoadConfig loads the database configuration from a YAML file. The standard libpq
+// variables PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE, PGSSLMODE and PGPASSFILE,
+// plus PGSEARCHPATH, override the file so one config can be reused across environments.
+// With an empty file name the configuration comes from the environment alone.
 func LoadConfig(file string) (*Config, error) {
 	config := &Config{}
-	yamlFile, err := os.ReadFile(file)
-	if err != nil {
-		return nil, err
+	if file != "" {
+		yamlFile, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		err = yaml.Unmarshal(yamlFile, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.Database.Driver == "" {
+		config.Database.Driver = "postgres"
 	}
-	err = yaml.Unmarshal(yamlFile, config)
+	err := config.applyEnv()
 	if err != nil {
 		return nil, err
 	}
 	return config, nil
 }
 
+// applyEnv overrides the configuration with the PG* environment variables that are set.
+func (c *Config) applyEnv() error {
+	d := &c.Database
+	for env, field := range map[string]*string{
+		"PGHOST":       &d.Host,
+		"PGUSER":       &d.User,
+		"PGPASSWORD":   &d.Password,
+		"PGDATABASE":   &d.DBName,
+		"PGSSLMODE":    &d.SSLMode,
+		"PGSEARCHPATH": &d.SearchPath,
+		"PGPASSFILE":   &d.PassFile,
+	} {
+		if v, ok := os.LookupEnv(env); ok {
+			*field = v
+		}
+	}
+	if v, ok := os.LookupEnv("PGPORT"); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		d.Port = port
+	}
+	return nil
+}
+
 ###########################################
 
 package dbutils
@@ -50,6 +95,9 @@ package dbutils
 import (
 	"database/sql"
 	"fmt"
+	"os"
+	"strings"
+
 	_ "github.com/lib/pq" // Import the required database driver
 )
 
@@ -60,15 +108,52 @@ type DB struct {
 
 // ConnectDB establishes a connection to the database using the provided configuration.
 func ConnectDB(cfg *Config) (*DB, error) {
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.DBName)
-	db, err := sql.Open(cfg.Database.Driver, connStr)
+	if cfg.Database.PassFile != "" && os.Getenv("PGPASSFILE") == "" {
+		os.Setenv("PGPASSFILE", cfg.Database.PassFile)
+	}
+	db, err := sql.Open(cfg.Database.Driver, cfg.ConnString())
 	if err != nil {
 		return nil, err
 	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return &DB{db}, nil
 }
 
+// ConnString returns the libpq connection string for the configuration. Empty settings are
+// left out so that lib/pq falls back to its defaults, and an empty password to the .pgpass file.
+func (c *Config) ConnString() string {
+	d := c.Database
+	if d.SSLMode == "" {
+		d.SSLMode = "disable"
+	}
+	var parts []string
+	for _, kv := range [][2]string{
+		{"host", d.Host},
+		{"user", d.User},
+		{"password", d.Password},
+		{"dbname", d.DBName},
+		{"sslmode", d.SSLMode},
+		{"search_path", d.SearchPath}, // sent to the server as a run-time parameter
+	} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+quoteConnValue(kv[1]))
+		}
+	}
+	if d.Port != 0 {
+		parts = append(parts, fmt.Sprintf("port=%d", d.Port))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteConnValue quotes a connection string value, escaping quotes and backslashes.
+func quoteConnValue(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v)
+	return "'" + v + "'"
+}
+
 // Insert executes an INSERT query with given parameters.
 func (db *DB) Insert(query string, args ...interface{}) error {
 	_, err := db.Exec(query, args...)
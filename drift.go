@@ -0,0 +1,201 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// DriftReport compares the ACLs implied by data_domain_identities with the ACLs on a cluster.
+type DriftReport struct {
+	Cluster           string   `json:"cluster"`
+	Missing           []ACL    `json:"missing"`           // expected but not on the cluster
+	Unexpected        []ACL    `json:"unexpected"`        // on the cluster for a known identity but not expected
+	UnknownPrincipals []string `json:"unknownPrincipals"` // User principals on the cluster not in technical_identities
+}
+
+// HasDrift reports whether the cluster differs from the identity database.
+func (d *DriftReport) HasDrift() bool {
+	return len(d.Missing) > 0 || len(d.Unexpected) > 0 || len(d.UnknownPrincipals) > 0
+}
+
+// computeDrift builds a drift report for the cluster from the identity database
+func computeDrift(db *sql.DB, c *Cluster) (*DriftReport, error) {
+	mappings, err := loadDomainIdentities(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load domain identities: %w", err)
+	}
+	known, err := loadIdentities(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load identities: %w", err)
+	}
+	actual, err := listACLs(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ACLs: %w", err)
+	}
+	expected := generateACLs(mappings, aclConvention)
+
+	report := &DriftReport{
+		Cluster:           c.Name,
+		Missing:           missingACLs(expected, actual),
+		UnknownPrincipals: []string{},
+	}
+	unknown := map[string]struct{}{}
+	for _, a := range missingACLs(actual, expected) {
+		identity, isUser := strings.CutPrefix(a.Principal, "User:")
+		if !isUser {
+			continue
+		}
+		if _, ok := known[identity]; ok {
+			report.Unexpected = append(report.Unexpected, a)
+		} else {
+			unknown[a.Principal] = struct{}{}
+		}
+	}
+	for p := range unknown {
+		report.UnknownPrincipals = append(report.UnknownPrincipals, p)
+	}
+	sort.Strings(report.UnknownPrincipals)
+	if report.Unexpected == nil {
+		report.Unexpected = []ACL{}
+	}
+	return report, nil
+}
+
+// loadIdentities returns the set of identities in technical_identities
+func loadIdentities(db *sql.DB) (map[string]struct{}, error) {
+	rows, err := db.Query("SELECT identity FROM technical_identities")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := make(map[string]struct{})
+	for rows.Next() {
+		var identity string
+		if err := rows.Scan(&identity); err != nil {
+			return nil, err
+		}
+		identities[identity] = struct{}{}
+	}
+	return identities, rows.Err()
+}
+
+// handleDrift handles requests to the /acls/drift endpoint
+func handleDrift(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if db == nil {
+		writeError(w, http.StatusServiceUnavailable, "Identity database is not configured")
+		return
+	}
+	cluster, err := requestCluster(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	report, err := computeDrift(db, cluster)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to compute drift: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// runDrift implements the "drift" subcommand. It exits 0 when in sync, 2 on drift and 1 on error.
+func runDrift(args []string) int {
+	// ContinueOnError: flag.ExitOnError would exit 2 on a usage error, which CI reads as drift
+	fs := flag.NewFlagSet("drift", flag.ContinueOnError)
+	clusterName := fs.String("cluster", "default", "Cluster to compare")
+	format := fs.String("format", "table", "Output format: table, json or csv")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 1
+	}
+
+	if db == nil {
+		log.Print("drift requires -db")
+		return 1
+	}
+	cluster, err := lookupCluster(*clusterName)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	report, err := computeDrift(db, cluster)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if err := writeDrift(os.Stdout, report, *format); err != nil {
+		log.Print(err)
+		return 1
+	}
+	if report.HasDrift() {
+		return 2
+	}
+	return 0
+}
+
+// writeDrift renders the report as a table, JSON or CSV with one row per finding
+func writeDrift(out io.Writer, report *DriftReport, format string) error {
+	type row struct{ kind, principal, operation, resource string }
+	var rows []row
+	for _, a := range report.Missing {
+		rows = append(rows, row{"missing", a.Principal, a.Operation, aclResource(a)})
+	}
+	for _, a := range report.Unexpected {
+		rows = append(rows, row{"unexpected", a.Principal, a.Operation, aclResource(a)})
+	}
+	for _, p := range report.UnknownPrincipals {
+		rows = append(rows, row{"unknown-principal", p, "", ""})
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "csv":
+		cw := csv.NewWriter(out)
+		cw.Write([]string{"drift", "principal", "operation", "resource"})
+		for _, r := range rows {
+			cw.Write([]string{r.kind, r.principal, r.operation, r.resource})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "DRIFT\tPRINCIPAL\tOPERATION\tRESOURCE")
+		for _, r := range rows {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.kind, r.principal, r.operation, r.resource)
+		}
+		if len(rows) == 0 {
+			fmt.Fprintf(tw, "cluster %s matches the identity database\n", report.Cluster)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// aclResource formats the resource pattern of an ACL, e.g. TOPIC:orders.* for a prefixed topic
+func aclResource(a ACL) string {
+	name := a.ResourceName
+	if a.PatternType == "PREFIXED" {
+		name += "*"
+	}
+	return a.ResourceType + ":" + name
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// EventType names an administrative change made through the service. Nothing publishes
+// topic.altered or offsets.reset yet; they are reserved for when topics can be altered and
+// group offsets reset through the service.
+type EventType string
+
+const (
+	EventTopicCreated   EventType = "topic.created"
+	EventTopicDeleted   EventType = "topic.deleted"
+	EventTopicAltered   EventType = "topic.altered"
+	EventACLChanged     EventType = "acl.changed"
+	EventOffsetsReset   EventType = "offsets.reset"
+	EventConnectChanged EventType = "connector.changed"
+)
+
+// Event is emitted on the bus after a change has been applied to a cluster.
+type Event struct {
+	ID      string      `json:"id"`
+	Type    EventType   `json:"type"`
+	Cluster string      `json:"cluster"`
+	Time    time.Time   `json:"time"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// EventBus fans events out to subscribers. Subscribers are called synchronously
+// and must not block; slow work such as HTTP delivery belongs on a queue.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+var events = &EventBus{}
+
+// Subscribe registers fn to receive every event published after the call.
+func (b *EventBus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, fn)
+}
+
+// Publish stamps the event with an id and time and hands it to every subscriber.
+func (b *EventBus) Publish(e Event) {
+	if e.ID == "" {
+		e.ID = newEventID()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, fn := range b.subscribers {
+		fn(e)
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GroupTopicLag is the lag of one consumer group on one topic.
+type GroupTopicLag struct {
+	Topic      string `json:"topic"`
+	Partitions int    `json:"partitions"`
+	Lag        int64  `json:"lag"`
+}
+
+// ConsumerGroup is a consumer group with its per-topic lag.
+type ConsumerGroup struct {
+	Group    string          `json:"group"`
+	TotalLag int64           `json:"totalLag"`
+	Members  int             `json:"members"`
+	Topics   []GroupTopicLag `json:"topics"`
+}
+
+// handleGroups handles requests to the /groups endpoint
+func handleGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	cluster, err := requestCluster(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	groups, err := describeGroupsInPod(cluster)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to describe consumer groups: "+err.Error())
+		return
+	}
+	if topic := r.URL.Query().Get("topic"); topic != "" {
+		filtered := []ConsumerGroup{}
+		for _, g := range groups {
+			for _, t := range g.Topics {
+				if t.Topic == topic {
+					filtered = append(filtered, g)
+					break
+				}
+			}
+		}
+		groups = filtered
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// describeGroupsInPod runs kafka-consumer-groups.sh --describe --all-groups and sums lag per group and topic
+func describeGroupsInPod(c *Cluster) ([]ConsumerGroup, error) {
+	output, err := execInPod(c, "kafka-consumer-groups.sh --describe --all-groups "+bootstrapArg)
+	if err != nil {
+		return nil, err
+	}
+
+	type groupState struct {
+		group   *ConsumerGroup
+		topics  map[string]*GroupTopicLag
+		members map[string]struct{}
+	}
+	byGroup := map[string]*groupState{}
+
+	// Columns: GROUP TOPIC PARTITION CURRENT-OFFSET LOG-END-OFFSET LAG CONSUMER-ID HOST CLIENT-ID
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		cols := strings.Fields(scanner.Text())
+		if len(cols) < 7 || cols[0] == "GROUP" {
+			continue
+		}
+		gs, ok := byGroup[cols[0]]
+		if !ok {
+			gs = &groupState{
+				group:   &ConsumerGroup{Group: cols[0]},
+				topics:  map[string]*GroupTopicLag{},
+				members: map[string]struct{}{},
+			}
+			byGroup[cols[0]] = gs
+		}
+		t, ok := gs.topics[cols[1]]
+		if !ok {
+			t = &GroupTopicLag{Topic: cols[1]}
+			gs.topics[cols[1]] = t
+		}
+		t.Partitions++
+		if lag, err := strconv.ParseInt(cols[5], 10, 64); err == nil {
+			t.Lag += lag
+			gs.group.TotalLag += lag
+		}
+		if cols[6] != "-" {
+			gs.members[cols[6]] = struct{}{}
+		}
+	}
+
+	groups := make([]ConsumerGroup, 0, len(byGroup))
+	for _, gs := range byGroup {
+		g := gs.group
+		g.Members = len(gs.members)
+		for _, t := range gs.topics {
+			g.Topics = append(g.Topics, *t)
+		}
+		sort.Slice(g.Topics, func(i, j int) bool { return g.Topics[i].Topic < g.Topics[j].Topic })
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Group < groups[j].Group })
+	return groups, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// partitionChecks are the kafka-topics.sh filters evaluated by /health/partitions, most severe first.
+var partitionChecks = []struct {
+	problem  string
+	filter   string
+	critical bool
+}{
+	{"unavailable", "--unavailable-partitions", true},
+	{"under-min-isr", "--under-min-isr-partitions", true},
+	{"under-replicated", "--under-replicated-partitions", false},
+}
+
+// problemPartition is a partition together with every check it failed.
+type problemPartition struct {
+	PartitionState
+	Problems []string `json:"problems"`
+}
+
+// partitionHealth is the body returned by /health/partitions.
+type partitionHealth struct {
+	Cluster string                        `json:"cluster"`
+	Status  string                        `json:"status"` // ok, degraded or critical
+	Topics  map[string][]problemPartition `json:"topics"`
+}
+
+// handlePartitionHealth handles requests to /health/partitions. It answers 200 when every
+// partition is healthy and 503 otherwise, so it can be used directly as a monitoring probe.
+func handlePartitionHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	cluster, err := requestCluster(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	health, err := checkPartitions(cluster)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to check partitions: "+err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	if health.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+// checkPartitions runs every partition check against the cluster and groups the findings by topic
+func checkPartitions(c *Cluster) (*partitionHealth, error) {
+	health := &partitionHealth{Cluster: c.Name, Status: "ok", Topics: map[string][]problemPartition{}}
+	byPartition := map[string]*problemPartition{}
+	var order []string
+
+	for _, check := range partitionChecks {
+		partitions, err := describePartitions(c, check.filter)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", check.filter, err)
+		}
+		for _, p := range partitions {
+			key := fmt.Sprintf("%s-%d", p.Topic, p.Partition)
+			pp, ok := byPartition[key]
+			if !ok {
+				pp = &problemPartition{PartitionState: p}
+				byPartition[key] = pp
+				order = append(order, key)
+			}
+			pp.Problems = append(pp.Problems, check.problem)
+			if check.critical {
+				health.Status = "critical"
+			} else if health.Status == "ok" {
+				health.Status = "degraded"
+			}
+		}
+	}
+
+	for _, key := range order {
+		pp := byPartition[key]
+		health.Topics[pp.Topic] = append(health.Topics[pp.Topic], *pp)
+	}
+	for _, partitions := range health.Topics {
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i].Partition < partitions[j].Partition })
+	}
+	return health, nil
+}
@@ -0,0 +1,90 @@
+// Command identity-api serves the dbcon identity API (identities, domains, mappings and
+// their audit log) over HTTP. Every request needs a bearer token from the -tokens file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"devcloude.ubs.net/ubs/eis/golang-common/dbcon"
+	"gopkg.in/yaml.v2"
+)
+
+// loadYAML reads file into v
+func loadYAML(file string, v interface{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+func main() {
+	configFile := flag.String("config", "config.yaml", "Path to the dbcon YAML database configuration")
+	tokenFile := flag.String("tokens", "", "Path to a YAML file of API bearer tokens (required)")
+	addr := flag.String("listen", ":8081", "Address to listen on")
+	createSchema := flag.Bool("create-schema", false, "Create missing identity tables on startup")
+	flag.Parse()
+
+	if *tokenFile == "" {
+		log.Fatal("-tokens is required; the identity API does not serve unauthenticated requests")
+	}
+	var tf struct {
+		Tokens map[string]string `yaml:"tokens"`
+	}
+	if err := loadYAML(*tokenFile, &tf); err != nil {
+		log.Fatalf("Failed to load API tokens: %v", err)
+	}
+	if len(tf.Tokens) == 0 {
+		log.Fatalf("No tokens in %s", *tokenFile)
+	}
+
+	var cfg dbcon.Config
+	if err := loadYAML(*configFile, &cfg); err != nil {
+		log.Fatalf("Failed to load database config: %v", err)
+	}
+	db, err := dbcon.NewDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	defer db.Close()
+
+	api, err := dbcon.NewIdentityAPI(db, dbcon.BearerTokenActor(tf.Tokens))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *createSchema {
+		if err := api.CreateSchema(); err != nil {
+			log.Fatalf("Failed to create schema: %v", err)
+		}
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           api.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Starting identity API on %s", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
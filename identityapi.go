@@ -0,0 +1,755 @@
+package dbcon
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IdentityAPI serves CRUD endpoints for technical_identities, data_domains and
+// data_domain_identities. Every change is validated and written to identity_audit in the
+// same transaction, and mapping changes open or close their data_domain_identity_history row.
+// The SQL runs on PostgreSQL and SQLite.
+//
+//	GET    /identities?q=&limit=&offset=      POST /identities      {"identity": "app1", "owner": ...}
+//	GET    /identities/{identity}             DELETE /identities/{identity}
+//	PUT    /identities/{identity} {"owner", "team", "environment", "description", "expires"}
+//	GET    /domains?q=&limit=&offset=         POST /domains         {"domain": "sales"}
+//	GET    /domains/{domain}                  DELETE /domains/{domain}
+//	GET    /mappings?domain=&identity=&limit=&offset=
+//	POST   /mappings {"domain": "sales", "identity": "app1"}   409 if the identity has expired
+//	DELETE /mappings/{domain}/{identity}
+//	GET    /audit?limit=&offset=
+//
+// Filters accept the wildcards * and ?. Every request must be authenticated by Actor.
+type IdentityAPI struct {
+	DB *DBWrapper
+	// Actor authenticates a request and returns the name recorded in the audit log.
+	// Requests it returns an error for are answered with 401.
+	Actor func(r *http.Request) (string, error)
+}
+
+// actorKey is the request context key of the authenticated actor.
+type actorKey struct{}
+
+// errUnauthenticated is returned by BearerTokenActor for a missing or unknown token.
+var errUnauthenticated = errors.New("missing or unknown bearer token")
+
+// BearerTokenActor authenticates requests by bearer token; tokens maps each token to the
+// name recorded as actor, the layout of the topic service's -tokens file.
+func BearerTokenActor(tokens map[string]string) func(r *http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return "", errUnauthenticated
+		}
+		name := ""
+		for t, n := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				name = n
+			}
+		}
+		if name == "" {
+			return "", errUnauthenticated
+		}
+		return name, nil
+	}
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+var (
+	validIdentity = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+	validDomain   = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// invalidIdentity and invalidDomain are the messages for names that do not match validIdentity and validDomain.
+const (
+	invalidIdentity = "identity may only contain letters, digits and . _ @ -"
+	invalidDomain   = "domain may only contain letters, digits and . _ -"
+)
+
+// identityAPISchema creates the tables the API needs if they do not exist. The DDL is the
+// portable subset of script.go's, so a fresh SQLite file works for local development.
+var identityAPISchema = []string{
+	`CREATE TABLE IF NOT EXISTS technical_identities (
+		identity VARCHAR(255) NOT NULL PRIMARY KEY,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		owner VARCHAR(255) NOT NULL DEFAULT '',
+		team VARCHAR(255) NOT NULL DEFAULT '',
+		environment VARCHAR(32) NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		expires_at DATE
+	)`,
+	`CREATE TABLE IF NOT EXISTS data_domains (
+		domain_name VARCHAR(255) NOT NULL PRIMARY KEY,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS data_domain_identities (
+		identity VARCHAR(255) NOT NULL REFERENCES technical_identities(identity),
+		domain_name VARCHAR(255) NOT NULL REFERENCES data_domains(domain_name),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (identity, domain_name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS data_domain_identity_history (
+		identity VARCHAR(255) NOT NULL,
+		domain_name VARCHAR(255) NOT NULL,
+		valid_from TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		valid_to TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS identity_audit (
+		changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		actor VARCHAR(255) NOT NULL,
+		action VARCHAR(16) NOT NULL,
+		entity VARCHAR(32) NOT NULL,
+		entity_key VARCHAR(511) NOT NULL
+	)`,
+}
+
+// NewIdentityAPI returns the API for a PostgreSQL or SQLite database. actor authenticates
+// every request, e.g. BearerTokenActor.
+func NewIdentityAPI(db *DBWrapper, actor func(r *http.Request) (string, error)) (*IdentityAPI, error) {
+	switch db.Driver {
+	case "postgres", "sqlite":
+	default:
+		return nil, fmt.Errorf("identity API does not support driver %s", db.Driver)
+	}
+	if actor == nil {
+		return nil, errors.New("identity API requires an actor to authenticate requests")
+	}
+	return &IdentityAPI{DB: db, Actor: actor}, nil
+}
+
+// CreateSchema creates any missing tables.
+func (a *IdentityAPI) CreateSchema() error {
+	for _, ddl := range identityAPISchema {
+		if _, err := a.DB.Exec(ddl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler serving every endpoint. Requests Actor does not
+// authenticate are answered with 401.
+func (a *IdentityAPI) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/identities", a.handleIdentities)
+	mux.HandleFunc("/identities/", a.handleIdentities)
+	mux.HandleFunc("/domains", a.handleDomains)
+	mux.HandleFunc("/domains/", a.handleDomains)
+	mux.HandleFunc("/mappings", a.handleMappings)
+	mux.HandleFunc("/mappings/", a.handleMappings)
+	mux.HandleFunc("/audit", a.handleAudit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Actor == nil {
+			writeError(w, http.StatusUnauthorized, "no authentication configured")
+			return
+		}
+		actor, err := a.Actor(r)
+		if err == nil && actor == "" {
+			err = errUnauthenticated
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+	})
+}
+
+// page is one page of a list response.
+type page struct {
+	Items  interface{} `json:"items"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// Identity is a row of technical_identities with the domains it is mapped to.
+type Identity struct {
+	Identity    string   `json:"identity"`
+	CreatedAt   string   `json:"createdAt,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	Team        string   `json:"team,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Expires     string   `json:"expires,omitempty"` // YYYY-MM-DD
+	Domains     []string `json:"domains,omitempty"`
+}
+
+// identityColumns are scanned by scanIdentity; the expiry date is read as text on both databases
+const identityColumns = "identity, created_at, owner, team, environment, description, CAST(expires_at AS VARCHAR(10))"
+
+func scanIdentity(row interface{ Scan(...interface{}) error }) (Identity, error) {
+	var i Identity
+	var expires sql.NullString
+	err := row.Scan(&i.Identity, &i.CreatedAt, &i.Owner, &i.Team, &i.Environment, &i.Description, &expires)
+	i.Expires = expires.String
+	return i, err
+}
+
+// validateIdentity returns what is wrong with the identity, or an empty string
+func validateIdentity(i Identity) string {
+	if !validIdentity.MatchString(i.Identity) {
+		return invalidIdentity
+	}
+	if _, err := time.Parse("2006-01-02", i.Expires); i.Expires != "" && err != nil {
+		return "expires must be a YYYY-MM-DD date"
+	}
+	return ""
+}
+
+// nullIfEmpty stores an empty optional value as NULL
+func nullIfEmpty(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+// Domain is a row of data_domains with the identities mapped to it.
+type Domain struct {
+	Domain     string   `json:"domain"`
+	CreatedAt  string   `json:"createdAt,omitempty"`
+	Identities []string `json:"identities,omitempty"`
+}
+
+// Mapping is a row of data_domain_identities.
+type Mapping struct {
+	Domain    string `json:"domain"`
+	Identity  string `json:"identity"`
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+// AuditEntry is a row of identity_audit.
+type AuditEntry struct {
+	ChangedAt string `json:"changedAt"`
+	Actor     string `json:"actor"`
+	Action    string `json:"action"`
+	Entity    string `json:"entity"`
+	Key       string `json:"key"`
+}
+
+// errExists, errConflict and errMissing are mapped to 409, 409 and 422 by writeChangeError.
+var (
+	errExists   = errors.New("already exists")
+	errConflict = errors.New("conflict")
+	errMissing  = errors.New("missing")
+)
+
+// handleIdentities handles /identities and /identities/{identity}
+func (a *IdentityAPI) handleIdentities(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/identities"), "/")
+
+	switch {
+	case name == "" && r.Method == "GET":
+		items := []Identity{}
+		a.list(w, r, "technical_identities", "identity", identityColumns, func(rows *sql.Rows) error {
+			i, err := scanIdentity(rows)
+			if err != nil {
+				return err
+			}
+			items = append(items, i)
+			return nil
+		}, func() interface{} { return items })
+
+	case name == "" && r.Method == "POST":
+		var body Identity
+		if !decode(w, r, &body) {
+			return
+		}
+		if msg := validateIdentity(body); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+		err := a.change(r, "create", "identity", body.Identity, func(tx *sql.Tx) error {
+			return insertUnique(tx, `INSERT INTO technical_identities (identity, owner, team, environment, description, expires_at)
+				SELECT $1, $2, $3, $4, $5, $6 WHERE NOT EXISTS (SELECT 1 FROM technical_identities WHERE identity = $1)`,
+				body.Identity, body.Owner, body.Team, body.Environment, body.Description, nullIfEmpty(body.Expires))
+		})
+		if writeChangeError(w, err, "identity "+body.Identity) {
+			return
+		}
+		body.CreatedAt, body.Domains = "", nil
+		writeJSON(w, http.StatusCreated, body)
+
+	case name != "" && r.Method == "PUT":
+		// Replaces the metadata; the identity itself cannot be renamed
+		var body Identity
+		if !decode(w, r, &body) {
+			return
+		}
+		body.Identity = name
+		if msg := validateIdentity(body); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+		err := a.change(r, "update", "identity", name, func(tx *sql.Tx) error {
+			// Placeholders in order of appearance: SQLite numbers $N parameters as it meets them
+			return execOne(tx, `UPDATE technical_identities SET owner = $1, team = $2, environment = $3, description = $4, expires_at = $5
+				WHERE identity = $6`, body.Owner, body.Team, body.Environment, body.Description, nullIfEmpty(body.Expires), name)
+		})
+		if writeChangeError(w, err, "identity "+name) {
+			return
+		}
+		body.CreatedAt, body.Domains = "", nil
+		writeJSON(w, http.StatusOK, body)
+
+	case name != "" && r.Method == "GET":
+		i, err := scanIdentity(a.DB.DB.QueryRow("SELECT "+identityColumns+" FROM technical_identities WHERE identity = $1", name))
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "identity "+name+" not found")
+			return
+		}
+		if err == nil {
+			i.Domains, err = a.queryStrings("SELECT domain_name FROM data_domain_identities WHERE identity = $1 ORDER BY domain_name", name)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, i)
+
+	case name != "" && r.Method == "DELETE":
+		// ?cascade=true also removes the identity's mappings; otherwise mapped identities are kept
+		err := a.change(r, "delete", "identity", name, func(tx *sql.Tx) error {
+			if r.URL.Query().Get("cascade") == "true" {
+				if err := closeHistory(tx, "identity = $1", name); err != nil {
+					return err
+				}
+				if _, err := tx.Exec("DELETE FROM data_domain_identities WHERE identity = $1", name); err != nil {
+					return err
+				}
+			} else if mapped, err := exists(tx, "SELECT 1 FROM data_domain_identities WHERE identity = $1", name); err != nil {
+				return err
+			} else if mapped {
+				return fmt.Errorf("%w: identity %s is still mapped to domains; use ?cascade=true", errConflict, name)
+			}
+			return execOne(tx, "DELETE FROM technical_identities WHERE identity = $1", name)
+		})
+		if writeChangeError(w, err, "identity "+name) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleDomains handles /domains and /domains/{domain}
+func (a *IdentityAPI) handleDomains(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/domains"), "/")
+
+	switch {
+	case name == "" && r.Method == "GET":
+		items := []Domain{}
+		a.list(w, r, "data_domains", "domain_name", "domain_name, created_at", func(rows *sql.Rows) error {
+			var d Domain
+			if err := rows.Scan(&d.Domain, &d.CreatedAt); err != nil {
+				return err
+			}
+			items = append(items, d)
+			return nil
+		}, func() interface{} { return items })
+
+	case name == "" && r.Method == "POST":
+		var body struct {
+			Domain string `json:"domain"`
+		}
+		if !decode(w, r, &body) {
+			return
+		}
+		if !validDomain.MatchString(body.Domain) {
+			writeError(w, http.StatusBadRequest, invalidDomain)
+			return
+		}
+		err := a.change(r, "create", "domain", body.Domain, func(tx *sql.Tx) error {
+			return insertUnique(tx, "INSERT INTO data_domains (domain_name) SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM data_domains WHERE domain_name = $1)", body.Domain)
+		})
+		if writeChangeError(w, err, "domain "+body.Domain) {
+			return
+		}
+		writeJSON(w, http.StatusCreated, Domain{Domain: body.Domain})
+
+	case name != "" && r.Method == "GET":
+		var d Domain
+		err := a.DB.DB.QueryRow("SELECT domain_name, created_at FROM data_domains WHERE domain_name = $1", name).Scan(&d.Domain, &d.CreatedAt)
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "domain "+name+" not found")
+			return
+		}
+		if err == nil {
+			d.Identities, err = a.queryStrings("SELECT identity FROM data_domain_identities WHERE domain_name = $1 ORDER BY identity", name)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, d)
+
+	case name != "" && r.Method == "DELETE":
+		err := a.change(r, "delete", "domain", name, func(tx *sql.Tx) error {
+			if r.URL.Query().Get("cascade") == "true" {
+				if err := closeHistory(tx, "domain_name = $1", name); err != nil {
+					return err
+				}
+				if _, err := tx.Exec("DELETE FROM data_domain_identities WHERE domain_name = $1", name); err != nil {
+					return err
+				}
+			} else if mapped, err := exists(tx, "SELECT 1 FROM data_domain_identities WHERE domain_name = $1", name); err != nil {
+				return err
+			} else if mapped {
+				return fmt.Errorf("%w: domain %s still has identities; use ?cascade=true", errConflict, name)
+			}
+			return execOne(tx, "DELETE FROM data_domains WHERE domain_name = $1", name)
+		})
+		if writeChangeError(w, err, "domain "+name) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleMappings handles /mappings and /mappings/{domain}/{identity}
+func (a *IdentityAPI) handleMappings(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/mappings"), "/")
+
+	switch {
+	case path == "" && r.Method == "GET":
+		limit, offset, ok := pagination(w, r)
+		if !ok {
+			return
+		}
+		q := r.URL.Query()
+		where := "domain_name LIKE $1 ESCAPE '\\' AND identity LIKE $2 ESCAPE '\\'"
+		args := []interface{}{likePattern(q.Get("domain")), likePattern(q.Get("identity"))}
+		var total int
+		if err := a.DB.DB.QueryRow("SELECT COUNT(*) FROM data_domain_identities WHERE "+where, args...).Scan(&total); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		rows, err := a.DB.Query("SELECT domain_name, identity, created_at FROM data_domain_identities WHERE "+where+
+			" ORDER BY domain_name, identity LIMIT $3 OFFSET $4", append(args, limit, offset)...)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer rows.Close()
+		items := []Mapping{}
+		for rows.Next() {
+			var m Mapping
+			if err := rows.Scan(&m.Domain, &m.Identity, &m.CreatedAt); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			items = append(items, m)
+		}
+		writeJSON(w, http.StatusOK, page{items, total, limit, offset})
+
+	case path == "" && r.Method == "POST":
+		var m Mapping
+		if !decode(w, r, &m) {
+			return
+		}
+		switch {
+		case m.Domain == "" || m.Identity == "":
+			writeError(w, http.StatusBadRequest, "domain and identity are required")
+			return
+		case !validDomain.MatchString(m.Domain):
+			writeError(w, http.StatusBadRequest, invalidDomain)
+			return
+		case !validIdentity.MatchString(m.Identity):
+			writeError(w, http.StatusBadRequest, invalidIdentity)
+			return
+		}
+		err := a.change(r, "create", "mapping", m.Domain+"/"+m.Identity, func(tx *sql.Tx) error {
+			// an expired identity gets no new access; the ingest's expire command revokes what it had
+			var expires sql.NullString
+			err := tx.QueryRow("SELECT CAST(expires_at AS VARCHAR(10)) FROM technical_identities WHERE identity = $1", m.Identity).Scan(&expires)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: identity %s does not exist", errMissing, m.Identity)
+			} else if err != nil {
+				return err
+			}
+			if expires.Valid && expires.String <= time.Now().Format("2006-01-02") {
+				return fmt.Errorf("%w: identity %s expired on %s", errConflict, m.Identity, expires.String)
+			}
+			if found, err := exists(tx, "SELECT 1 FROM data_domains WHERE domain_name = $1", m.Domain); err != nil {
+				return err
+			} else if !found {
+				return fmt.Errorf("%w: domain %s does not exist", errMissing, m.Domain)
+			}
+			err = insertUnique(tx, `INSERT INTO data_domain_identities (identity, domain_name) SELECT $1, $2
+				WHERE NOT EXISTS (SELECT 1 FROM data_domain_identities WHERE identity = $1 AND domain_name = $2)`, m.Identity, m.Domain)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("INSERT INTO data_domain_identity_history (identity, domain_name) VALUES ($1, $2)", m.Identity, m.Domain)
+			return err
+		})
+		if writeChangeError(w, err, "mapping "+m.Domain+" -> "+m.Identity) {
+			return
+		}
+		writeJSON(w, http.StatusCreated, m)
+
+	case path != "" && r.Method == "DELETE":
+		domain, identity, ok := strings.Cut(path, "/")
+		if !ok || domain == "" || identity == "" {
+			writeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		err := a.change(r, "delete", "mapping", domain+"/"+identity, func(tx *sql.Tx) error {
+			if err := execOne(tx, "DELETE FROM data_domain_identities WHERE domain_name = $1 AND identity = $2", domain, identity); err != nil {
+				return err
+			}
+			return closeHistory(tx, "domain_name = $1 AND identity = $2", domain, identity)
+		})
+		if writeChangeError(w, err, "mapping "+domain+" -> "+identity) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleAudit handles /audit, newest changes first
+func (a *IdentityAPI) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	items := []AuditEntry{}
+	a.list(w, r, "identity_audit", "entity_key", "changed_at, actor, action, entity, entity_key", func(rows *sql.Rows) error {
+		var e AuditEntry
+		if err := rows.Scan(&e.ChangedAt, &e.Actor, &e.Action, &e.Entity, &e.Key); err != nil {
+			return err
+		}
+		items = append(items, e)
+		return nil
+	}, func() interface{} { return items })
+}
+
+// list writes one page of a table filtered by ?q= on the key column
+func (a *IdentityAPI) list(w http.ResponseWriter, r *http.Request, table, key, columns string, scan func(*sql.Rows) error, items func() interface{}) {
+	limit, offset, ok := pagination(w, r)
+	if !ok {
+		return
+	}
+	filter := likePattern(r.URL.Query().Get("q"))
+	order := key
+	if table == "identity_audit" {
+		order = "changed_at DESC"
+	}
+
+	var total int
+	err := a.DB.DB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE "+key+" LIKE $1 ESCAPE '\\'", filter).Scan(&total)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rows, err := a.DB.Query("SELECT "+columns+" FROM "+table+" WHERE "+key+" LIKE $1 ESCAPE '\\' ORDER BY "+order+" LIMIT $2 OFFSET $3", filter, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, page{items(), total, limit, offset})
+}
+
+// change runs fn and records the audit entry in one transaction
+func (a *IdentityAPI) change(r *http.Request, action, entity, key string, fn func(tx *sql.Tx) error) error {
+	tx, err := a.DB.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	actor := a.actor(r)
+	if _, err := tx.Exec("INSERT INTO identity_audit (actor, action, entity, entity_key) VALUES ($1, $2, $3, $4)", actor, action, entity, key); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logger.WithFields(logrus.Fields{"actor": actor, "action": action, "entity": entity, "key": key}).Info("Identity data changed")
+	return nil
+}
+
+// actor returns the name Handler authenticated the request as
+func (a *IdentityAPI) actor(r *http.Request) string {
+	actor, _ := r.Context().Value(actorKey{}).(string)
+	return actor
+}
+
+// queryStrings runs a query returning one string column
+func (a *IdentityAPI) queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := a.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// insertUnique runs an INSERT ... WHERE NOT EXISTS and reports errExists when no row was added
+func insertUnique(tx *sql.Tx, query string, args ...interface{}) error {
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errExists
+	}
+	return nil
+}
+
+// execOne runs an UPDATE or DELETE and reports sql.ErrNoRows when no row was changed
+func execOne(tx *sql.Tx, query string, args ...interface{}) error {
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// closeHistory ends the validity of the open history rows matching the condition
+func closeHistory(tx *sql.Tx, where string, args ...interface{}) error {
+	_, err := tx.Exec("UPDATE data_domain_identity_history SET valid_to = CURRENT_TIMESTAMP WHERE valid_to IS NULL AND "+where, args...)
+	return err
+}
+
+func exists(tx *sql.Tx, query string, args ...interface{}) (bool, error) {
+	var one int
+	err := tx.QueryRow(query, args...).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// writeChangeError writes the error response for a failed change and reports whether there was one
+func writeChangeError(w http.ResponseWriter, err error, what string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, what+" not found")
+	case errors.Is(err, errExists):
+		writeError(w, http.StatusConflict, what+" already exists")
+	case errors.Is(err, errConflict):
+		writeError(w, http.StatusConflict, strings.TrimPrefix(err.Error(), "conflict: "))
+	case errors.Is(err, errMissing):
+		writeError(w, http.StatusUnprocessableEntity, strings.TrimPrefix(err.Error(), "missing: "))
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+	return true
+}
+
+// pagination reads ?limit= and ?offset=, writing a 400 when they are invalid
+func pagination(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	limit, offset = defaultPageSize, 0
+	q := r.URL.Query()
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxPageSize {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+			return 0, 0, false
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "offset must be a non-negative number")
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
+
+// likePattern turns a * and ? wildcard filter into a LIKE pattern; an empty filter matches everything
+func likePattern(p string) string {
+	if p == "" {
+		return "%"
+	}
+	p = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(p)
+	return strings.NewReplacer("*", "%", "?", "_").Replace(p)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the same error body as the topic service: {"error": {"code", "message"}}
+func writeError(w http.ResponseWriter, status int, message string) {
+	codes := map[int]string{
+		http.StatusBadRequest:          "bad_request",
+		http.StatusUnauthorized:        "unauthorized",
+		http.StatusNotFound:            "not_found",
+		http.StatusMethodNotAllowed:    "method_not_allowed",
+		http.StatusConflict:            "conflict",
+		http.StatusUnprocessableEntity: "unprocessable",
+	}
+	code, ok := codes[status]
+	if !ok {
+		code = "internal"
+	}
+	writeJSON(w, status, map[string]interface{}{"error": map[string]string{"code": code, "message": message}})
+}
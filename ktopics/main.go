@@ -0,0 +1,352 @@
+// ktopics is a command-line client for the topic REST service (listtopic.go), built on the
+// topicclient package.
+//
+// Usage:
+//
+//	ktopics [-server URL] [-cluster NAME] [-o table|json|yaml] <command> [args]
+//
+// Commands: clusters, list, describe TOPIC, create TOPIC, delete TOPIC, acls, groups.
+//
+// Defaults for -server, -cluster, -o and the bearer token are read from
+// ~/.config/ktopics.yaml (or -config), e.g.
+//
+//	server: https://kafka-topics.example.internal
+//	token: s3cr3t
+//	cluster: dev
+//
+// Exit codes: 0 success, 1 usage or configuration error, 2 request failed or server error,
+// 3 not found, 4 request rejected by the server (validation, auth).
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"devcloude.ubs.net/ubs/eis/kafka-topic-service/topicclient"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	exitOK       = 0
+	exitUsage    = 1
+	exitFailed   = 2
+	exitNotFound = 3
+	exitRejected = 4
+)
+
+// config is the layout of the ktopics config file.
+type config struct {
+	Server  string `yaml:"server"`
+	Token   string `yaml:"token"`
+	Cluster string `yaml:"cluster"`
+	Output  string `yaml:"output"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	home, _ := os.UserHomeDir()
+	fs := flag.NewFlagSet("ktopics", flag.ContinueOnError)
+	configFile := fs.String("config", filepath.Join(home, ".config", "ktopics.yaml"), "Path to the ktopics config file")
+	server := fs.String("server", "", "Topic service URL (overrides config)")
+	cluster := fs.String("cluster", "", "Cluster name (overrides config)")
+	output := fs.String("o", "", "Output format: table, json or yaml (overrides config)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ktopics [flags] clusters|list|describe|create|delete|acls|groups [args]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ktopics:", err)
+		return exitUsage
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+	if *cluster != "" {
+		cfg.Cluster = *cluster
+	}
+	if *output != "" {
+		cfg.Output = *output
+	}
+	if token := os.Getenv("KTOPICS_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	if cfg.Server == "" {
+		cfg.Server = "http://localhost:8080"
+	}
+	if cfg.Output == "" {
+		cfg.Output = "table"
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	c := topicclient.New(cfg.Server, cfg.Token)
+	c.Cluster = cfg.Cluster
+	c.HTTPClient = &http.Client{Timeout: 60 * time.Second}
+	out := &printer{format: cfg.Output, w: os.Stdout}
+
+	cmd, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "clusters":
+		err = cmdClusters(c, out)
+	case "list":
+		err = cmdList(c, out)
+	case "describe":
+		err = cmdDescribe(c, out, cmdArgs)
+	case "create":
+		err = cmdCreate(c, cmdArgs)
+	case "delete":
+		err = cmdDelete(c, cmdArgs)
+	case "acls":
+		err = cmdACLs(c, out, cmdArgs)
+	case "groups":
+		err = cmdGroups(c, out, cmdArgs)
+	default:
+		fmt.Fprintf(os.Stderr, "ktopics: unknown command %q\n", cmd)
+		fs.Usage()
+		return exitUsage
+	}
+	return exitCode(err)
+}
+
+// exitCode maps an error onto the documented exit codes
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	fmt.Fprintln(os.Stderr, "ktopics:", err)
+	var ae *topicclient.Error
+	var ue usageError
+	switch {
+	case errors.As(err, &ue):
+		return exitUsage
+	case errors.As(err, &ae) && ae.Status == http.StatusNotFound:
+		return exitNotFound
+	case errors.As(err, &ae) && ae.Status >= 400 && ae.Status < 500:
+		return exitRejected
+	default:
+		return exitFailed
+	}
+}
+
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// loadConfig reads the config file; a missing file yields an empty config
+func loadConfig(file string) (*config, error) {
+	cfg := &config{}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", file, err)
+	}
+	return cfg, nil
+}
+
+func cmdClusters(c *topicclient.Client, out *printer) error {
+	clusters, err := c.ListClusters(context.Background())
+	if err != nil {
+		return err
+	}
+	return out.print(clusters, func(tw io.Writer) {
+		fmt.Fprintln(tw, "CLUSTER")
+		for _, name := range clusters {
+			fmt.Fprintln(tw, name)
+		}
+	})
+}
+
+func cmdList(c *topicclient.Client, out *printer) error {
+	topics, err := c.ListTopics(context.Background())
+	if err != nil {
+		return err
+	}
+	return out.print(topics, func(tw io.Writer) {
+		fmt.Fprintln(tw, "TOPIC")
+		for _, t := range topics {
+			fmt.Fprintln(tw, t)
+		}
+	})
+}
+
+func cmdDescribe(c *topicclient.Client, out *printer, args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: ktopics describe TOPIC")
+	}
+	topic, err := c.DescribeTopic(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	return out.print(topic, func(tw io.Writer) {
+		fmt.Fprintf(tw, "Topic:\t%s\nPartitions:\t%d\nReplicationFactor:\t%d\n", topic.Name, topic.Partitions, topic.ReplicationFactor)
+		keys := make([]string, 0, len(topic.Configs))
+		for k := range topic.Configs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "Config:\t%s=%s\n", k, topic.Configs[k])
+		}
+		fmt.Fprintln(tw, "\nPARTITION\tLEADER\tREPLICAS\tISR")
+		for _, p := range topic.PartitionStates {
+			fmt.Fprintf(tw, "%d\t%d\t%s\t%s\n", p.Partition, p.Leader, joinInts(p.Replicas), joinInts(p.Isr))
+		}
+	})
+}
+
+func cmdCreate(c *topicclient.Client, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	partitions := fs.Int("partitions", 0, "Number of partitions (broker default when 0)")
+	rf := fs.Int("replication-factor", 0, "Replication factor (broker default when 0)")
+	var configs stringList
+	fs.Var(&configs, "config", "Topic config as key=value (repeatable)")
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usageError("usage: ktopics create TOPIC [-partitions N] [-replication-factor N] [-config key=value ...]")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return usageError(err.Error())
+	}
+
+	req := topicclient.CreateTopicRequest{TopicName: args[0], Partitions: *partitions, ReplicationFactor: *rf}
+	if len(configs) > 0 {
+		req.Configs = map[string]string{}
+		for _, kv := range configs {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return usageError("invalid -config " + kv)
+			}
+			req.Configs[k] = v
+		}
+	}
+	if _, err := c.CreateTopic(context.Background(), req); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Topic %s created\n", args[0])
+	return nil
+}
+
+func cmdDelete(c *topicclient.Client, args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: ktopics delete TOPIC")
+	}
+	if err := c.DeleteTopic(context.Background(), args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Topic %s deleted\n", args[0])
+	return nil
+}
+
+func cmdACLs(c *topicclient.Client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("acls", flag.ContinueOnError)
+	principal := fs.String("principal", "", "Only show ACLs of this principal, e.g. User:svc-orders")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	acls, err := c.ListACLs(context.Background(), *principal)
+	if err != nil {
+		return err
+	}
+	return out.print(acls, func(tw io.Writer) {
+		fmt.Fprintln(tw, "PRINCIPAL\tPERMISSION\tOPERATION\tRESOURCE\tPATTERN\tHOST")
+		for _, a := range acls {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s:%s\t%s\t%s\n", a.Principal, a.Permission, a.Operation, a.ResourceType, a.ResourceName, a.PatternType, a.Host)
+		}
+	})
+}
+
+func cmdGroups(c *topicclient.Client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("groups", flag.ContinueOnError)
+	topic := fs.String("topic", "", "Only show groups consuming this topic")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	groups, err := c.ListGroups(context.Background(), *topic)
+	if err != nil {
+		return err
+	}
+	return out.print(groups, func(tw io.Writer) {
+		fmt.Fprintln(tw, "GROUP\tTOPIC\tPARTITIONS\tLAG\tMEMBERS")
+		for _, g := range groups {
+			for _, t := range g.Topics {
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\n", g.Group, t.Topic, t.Partitions, t.Lag, g.Members)
+			}
+		}
+	})
+}
+
+// printer renders a value as a table, JSON or YAML.
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+func (p *printer) print(v interface{}, table func(io.Writer)) error {
+	switch p.format {
+	case "json":
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		// the client types only carry json tags; go through JSON so YAML keys match the API
+		js, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		if err := yaml.Unmarshal(js, &generic); err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = p.w.Write(data)
+		return err
+	case "table":
+		tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	default:
+		return usageError("unknown output format " + p.format)
+	}
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+func joinInts(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	return strings.Join(parts, ",")
+}
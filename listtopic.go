@@ -1,177 +1,468 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/exec"
+	"k8s.io/client-go/tools/remotecommand"
 )
 
 var (
 	clientset    *kubernetes.Clientset
+	restConfig   *rest.Config
+	db           *sql.DB
 	podNamespace string
 	podName      string
 )
 
+// bootstrapArg points kafka-*.sh tools at the brokers using the TLS settings mounted in the pod
+const bootstrapArg = "--bootstrap-server $(cat /mnt/secrets/tls.sh)"
+
 func main() {
-	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file")
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file; uses the in-cluster ServiceAccount when empty")
+	kubeContext := flag.String("context", "", "Kubeconfig context to use instead of the current context")
+	impersonateUser := flag.String("as", "", "User to impersonate for Kubernetes API calls")
+	impersonateGroups := flag.String("as-group", "", "Comma-separated groups to impersonate (requires -as)")
 	flag.StringVar(&podNamespace, "namespace", "default", "Namespace of the Kafka pod")
 	flag.StringVar(&podName, "pod", "kafka-dev-0", "Name of the Kafka pod")
+	clusterFile := flag.String("clusters", "", "Path to a YAML file describing additional clusters")
+	connectURL := flag.String("connect-url", "", "Kafka Connect REST URL of the default cluster")
+	dbConnStr := flag.String("db", "", "PostgreSQL connection string of the identity database")
+	webhookFile := flag.String("webhooks", "", "Path to a YAML file listing webhook receivers for admin events")
+	tokenFile := flag.String("tokens", "", "Path to a YAML file of API bearer tokens; the API is open when unset")
+	namingFile := flag.String("naming-policy", "", "Path to a YAML file with the topic naming policy enforced on create")
+	deadLetterFile := flag.String("dead-letter", "webhook-dead-letter.jsonl", "File receiving events that could not be delivered to a webhook")
+	flag.StringVar(&aclConvention.TopicPrefix, "acl-topic-prefix", aclConvention.TopicPrefix, "Topic prefix granted to a data domain ({domain} is replaced)")
+	flag.StringVar(&aclConvention.GroupPrefix, "acl-group-prefix", aclConvention.GroupPrefix, "Consumer group prefix granted to a data domain ({domain} is replaced)")
+	serverConfig.registerFlags()
+	flag.Float64Var((*float64)(&limiter.rate), "rate", float64(limiter.rate), "Sustained requests per second allowed per client")
+	flag.IntVar(&limiter.burst, "burst", limiter.burst, "Requests a client may burst above -rate")
+	flag.IntVar(&execSlots.max, "max-exec-per-pod", execSlots.max, "Concurrent requests allowed to exec into one broker pod")
+	flag.DurationVar(&execSlots.wait, "exec-wait", execSlots.wait, "How long a request waits for a free exec slot before getting 429")
 	flag.Parse()
-
-	if *kubeconfig == "" {
-		fmt.Println("Error: kubeconfig path is required")
+	if *impersonateGroups != "" && *impersonateUser == "" {
+		fmt.Fprintln(flag.CommandLine.Output(), "-as-group requires -as")
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(2)
 	}
 
 	// Load Kubernetes config
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	var err error
+	restConfig, err = loadKubeConfig(*kubeconfig, *kubeContext)
 	if err != nil {
-		log.Fatalf("Failed to load kubeconfig: %v", err)
+		log.Fatalf("Failed to load Kubernetes config: %v", err)
+	}
+	if *impersonateUser != "" {
+		restConfig.Impersonate = rest.ImpersonationConfig{UserName: *impersonateUser}
+		if *impersonateGroups != "" {
+			restConfig.Impersonate.Groups = strings.Split(*impersonateGroups, ",")
+		}
 	}
 
 	// Create Kubernetes client
-	clientset, err = kubernetes.NewForConfig(config)
+	clientset, err = kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		log.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
 
-	// Set up REST API routes
-	http.HandleFunc("/topics", handleTopics)
-	log.Println("Starting server on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	// The flags describe the default cluster; -clusters may add more
+	clusters["default"] = &Cluster{Name: "default", Namespace: podNamespace, Pod: podName, ConnectURL: *connectURL}
+	if *clusterFile != "" {
+		if err := loadClusters(*clusterFile); err != nil {
+			log.Fatalf("Failed to load clusters: %v", err)
+		}
+	}
+
+	if *dbConnStr != "" {
+		db, err = sql.Open("postgres", *dbConnStr)
+		if err != nil {
+			log.Fatalf("Failed to open identity database: %v", err)
+		}
+		defer db.Close()
+	}
+
+	if *namingFile != "" {
+		namingPolicy, err = loadNamingPolicy(*namingFile)
+		if err != nil {
+			log.Fatalf("Failed to load naming policy: %v", err)
+		}
+		if db == nil {
+			log.Print("Naming policy domains are not checked without -db")
+		}
+	}
+
+	if *tokenFile != "" {
+		if err := loadTokens(*tokenFile); err != nil {
+			log.Fatalf("Failed to load API tokens: %v", err)
+		}
+	}
+
+	if *webhookFile != "" {
+		hooks, err := loadWebhooks(*webhookFile)
+		if err != nil {
+			log.Fatalf("Failed to load webhooks: %v", err)
+		}
+		dispatcher := NewWebhookDispatcher(hooks, *deadLetterFile)
+		dispatcher.Start()
+		shutdownHooks = append(shutdownHooks, dispatcher.Shutdown)
+		events.Subscribe(dispatcher.Enqueue)
+	}
+
+	// One-shot subcommands run against the same clusters and database as the server
+	if flag.NArg() > 0 {
+		var code int
+		switch flag.Arg(0) {
+		case "drift":
+			code = runDrift(flag.Args()[1:])
+		case "backup":
+			code = runBackup(flag.Args()[1:])
+		case "restore":
+			code = runRestore(flag.Args()[1:])
+		default:
+			log.Fatalf("Unknown command %q", flag.Arg(0))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
+		runShutdownHooks(ctx)
+		cancel()
+		os.Exit(code)
+	}
+
+	// Set up REST API routes; refuse to start if they drift from openapi.json
+	mux := newMux()
+	if err := checkSpecRoutes(mux); err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		for range time.Tick(10 * time.Minute) {
+			limiter.sweep(time.Hour)
+			authFailures.sweep(time.Hour)
+		}
+	}()
+	if err := serve(serverConfig, withAuth(withRateLimit(mux))); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// newMux registers every entry of routes, wrapping the ones that exec into the broker pod
+// in the exec limiter
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		if rt.execs {
+			mux.HandleFunc(rt.pattern, withExecLimit(rt.handler))
+		} else {
+			mux.HandleFunc(rt.pattern, rt.handler)
+		}
+	}
+	return mux
+}
+
+// routes lists every handler with the OpenAPI paths and methods it serves
+// and whether it execs into the broker pod (and so needs an exec slot)
+var routes = []struct {
+	pattern string
+	handler http.HandlerFunc
+	execs   bool
+	paths   map[string][]string
+}{
+	{"/openapi.json", handleOpenAPI, false, map[string][]string{"/openapi.json": {"GET"}}},
+	{"/metrics", promhttp.Handler().ServeHTTP, false, map[string][]string{"/metrics": {"GET"}}},
+	{"/clusters", handleClusters, false, map[string][]string{"/clusters": {"GET"}}},
+	{"/topics", handleTopics, true, map[string][]string{"/topics": {"GET", "POST"}}},
+	{"/topics/validate", handleValidateTopic, false, map[string][]string{"/topics/validate": {"POST"}}},
+	{"/topics/", handleTopic, true, map[string][]string{"/topics/{name}": {"GET", "DELETE"}}},
+	{"/catalog/topics", handleCatalog, false, map[string][]string{"/catalog/topics": {"GET"}}},
+	{"/catalog/topics/", handleCatalog, false, map[string][]string{"/catalog/topics/{name}": {"GET", "PUT", "DELETE"}}},
+	{"/catalog/uncatalogued", handleCatalog, true, map[string][]string{"/catalog/uncatalogued": {"GET"}}},
+	{"/groups", handleGroups, true, map[string][]string{"/groups": {"GET"}}},
+	{"/acls", handleACLs, true, map[string][]string{"/acls": {"GET", "POST", "DELETE"}}},
+	{"/acls/generated", handleGeneratedACLs, false, map[string][]string{"/acls/generated": {"GET"}}},
+	{"/acls/generated/apply", handleGeneratedACLs, true, map[string][]string{"/acls/generated/apply": {"POST"}}},
+	{"/acls/drift", handleDrift, true, map[string][]string{"/acls/drift": {"GET"}}},
+	{"/users", handleUsers, true, map[string][]string{"/users": {"GET"}}},
+	{"/users/", handleUsers, true, map[string][]string{"/users/{identity}/credentials": {"POST", "DELETE"}}},
+	{"/quotas/", handleQuotas, true, map[string][]string{"/quotas/{entityType}/{name}": {"PUT", "DELETE"}}},
+	{"/health/partitions", handlePartitionHealth, true, map[string][]string{"/health/partitions": {"GET"}}},
+	{"/connect/", handleConnect, false, map[string][]string{
+		"/connect/{cluster}/connectors":                             {"GET", "POST"},
+		"/connect/{cluster}/connectors/{name}":                      {"GET", "PUT", "DELETE"},
+		"/connect/{cluster}/connectors/{name}/{action}":             {"POST"},
+		"/connect/{cluster}/connectors/{name}/tasks/{task}/restart": {"POST"},
+	}},
+	{"/ui/", uiHandler().ServeHTTP, false, map[string][]string{"/ui/{file}": {"GET"}}},
+	{"/", handleRoot, false, map[string][]string{"/": {"GET"}}},
+}
+
+// loadKubeConfig builds the Kubernetes client config from a kubeconfig file and optional
+// context, or from the Pod's ServiceAccount when no kubeconfig is given
+func loadKubeConfig(kubeconfig, context string) (*rest.Config, error) {
+	if kubeconfig == "" {
+		if context != "" {
+			return nil, fmt.Errorf("-context requires -kubeconfig")
+		}
+		return rest.InClusterConfig()
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	).ClientConfig()
+}
+
+// createTopicRequest is the body of POST /topics.
+type createTopicRequest struct {
+	TopicName         string            `json:"topicName"`
+	Partitions        int               `json:"partitions"`
+	ReplicationFactor int               `json:"replicationFactor"`
+	Configs           map[string]string `json:"configs"`
+	Catalog           *CatalogEntry     `json:"catalog"`
 }
 
 // handleTopics handles requests to the /topics endpoint
 func handleTopics(w http.ResponseWriter, r *http.Request) {
+	cluster, err := requestCluster(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
 	switch r.Method {
 	case "GET":
 		// List topics
-		topics, err := listTopicsInPod()
+		topics, err := listTopicsInPod(cluster)
 		if err != nil {
-			http.Error(w, "Failed to list topics: "+err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, "Failed to list topics: "+err.Error())
 			return
 		}
-		json.NewEncoder(w).Encode(topics)
+		writeJSON(w, http.StatusOK, topics)
 
 	case "POST":
-		// Create a new topic (expecting JSON payload with "topicName" field)
-		var reqBody struct {
-			TopicName string `json:"topicName"`
-		}
+		// Create a new topic (expecting JSON payload with "topicName" and optional partitions, replicationFactor,
+		// configs and catalog)
+		var reqBody createTopicRequest
 		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.TopicName == "" {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
-
-		err := createTopicInPod(reqBody.TopicName)
+		violations, err := validateTopicName(reqBody.TopicName)
 		if err != nil {
-			http.Error(w, "Failed to create topic: "+err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(violations) > 0 {
+			writeError(w, http.StatusUnprocessableEntity, violationMessage(violations))
 			return
 		}
-		w.WriteHeader(http.StatusCreated)
-		fmt.Fprintf(w, "Topic %s created", reqBody.TopicName)
+		if reqBody.Catalog != nil && db == nil {
+			writeError(w, http.StatusBadRequest, "A catalog entry needs the identity database; the service runs without -db")
+			return
+		}
+		if reqBody.Catalog != nil {
+			if err := reqBody.Catalog.validate(db); err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "Invalid catalog entry: "+err.Error())
+				return
+			}
+		}
+
+		spec := TopicSpec{
+			Name:              reqBody.TopicName,
+			Partitions:        reqBody.Partitions,
+			ReplicationFactor: reqBody.ReplicationFactor,
+			Configs:           reqBody.Configs,
+			Catalog:           reqBody.Catalog,
+		}
+		if err := createTopicInPod(cluster, spec); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to create topic: "+err.Error())
+			return
+		}
+		events.Publish(Event{Type: EventTopicCreated, Cluster: cluster.Name, Data: spec})
+		writeJSON(w, http.StatusCreated, spec)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 	}
 }
 
-// listTopicsInPod executes the command in the pod to list Kafka topics
-func listTopicsInPod() ([]string, error) {
-	cmd := []string{
-		"/bin/sh", "-c", "kafka-topics.sh --list --bootstrap-server $(cat /mnt/secrets/tls.sh)",
+// handleTopic handles requests to the /topics/{name} endpoint
+func handleTopic(w http.ResponseWriter, r *http.Request) {
+	cluster, err := requestCluster(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/topics/")
+	if !resourceName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "Invalid topic name")
+		return
 	}
 
-	req := clientset.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(podName).
-		Namespace(podNamespace).
-		SubResource("exec").
-		Param("container", "kafka").
-		Param("stdin", "true").
-		Param("stdout", "true").
-		Param("stderr", "true").
-		Param("tty", "true")
+	switch r.Method {
+	case "GET":
+		// Describe a topic with its partitions
+		topic, err := describeTopicInPod(cluster, name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to describe topic: "+err.Error())
+			return
+		}
+		if topic == nil {
+			writeError(w, http.StatusNotFound, "Topic "+name+" not found")
+			return
+		}
+		if db != nil {
+			records, err := loadCatalog(db, cluster, name)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to load catalog: "+err.Error())
+				return
+			}
+			if len(records) > 0 {
+				topic.Catalog = &records[0].CatalogEntry
+			}
+		}
+		writeJSON(w, http.StatusOK, topic)
 
-	for _, arg := range cmd {
-		req.Param("command", arg)
-	}
+	case "DELETE":
+		if err := deleteTopicInPod(cluster, name); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to delete topic: "+err.Error())
+			return
+		}
+		if db != nil {
+			logCatalogError(name, deleteCatalogEntry(db, cluster, name))
+		}
+		events.Publish(Event{Type: EventTopicDeleted, Cluster: cluster.Name, Data: map[string]string{"topic": name}})
+		w.WriteHeader(http.StatusNoContent)
 
-	exec, err := exec.NewSPDYExecutor(clientset.RESTConfig(), "POST", req.URL())
-	if err != nil {
-		return nil, fmt.Errorf("failed to create executor: %w", err)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 	}
+}
 
-	// Capture output
-	output := &bytes.Buffer{}
-	err = exec.Stream(exec.StreamOptions{
-		IOStreams: exec.IOStreams{
-			Out: output,
-			ErrOut: os.Stderr,
-		},
-		Tty: false,
-	})
+// listTopicsInPod executes the command in the pod to list Kafka topics
+func listTopicsInPod(c *Cluster) ([]string, error) {
+	output, err := execInPod(c, "kafka-topics.sh --list "+bootstrapArg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute command in pod: %w", err)
+		return nil, err
 	}
 
 	// Parse topics from output
-	topics := strings.Split(strings.TrimSpace(output.String()), "\n")
+	topics := strings.Split(strings.TrimSpace(output), "\n")
 	return topics, nil
 }
 
 // createTopicInPod executes the command in the pod to create a new Kafka topic
-func createTopicInPod(topicName string) error {
-	cmd := []string{
-		"/bin/sh", "-c", fmt.Sprintf("kafka-topics.sh --create --topic %s --bootstrap-server $(cat /mnt/secrets/tls.sh)", topicName),
+func createTopicInPod(c *Cluster, spec TopicSpec) error {
+	if !resourceName.MatchString(spec.Name) {
+		return fmt.Errorf("invalid topic name %q", spec.Name)
+	}
+	cmd := fmt.Sprintf("kafka-topics.sh --create --topic %s %s", spec.Name, bootstrapArg)
+	if spec.Partitions > 0 {
+		cmd += fmt.Sprintf(" --partitions %d", spec.Partitions)
+	}
+	if spec.ReplicationFactor > 0 {
+		cmd += fmt.Sprintf(" --replication-factor %d", spec.ReplicationFactor)
+	}
+	keys := make([]string, 0, len(spec.Configs))
+	for k := range spec.Configs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := spec.Configs[k]
+		if !resourceName.MatchString(k) || strings.Contains(v, "'") {
+			return fmt.Errorf("invalid config %s=%s", k, v)
+		}
+		cmd += fmt.Sprintf(" --config '%s=%s'", k, v)
+	}
+
+	output, err := execInPod(c, cmd)
+	if err != nil {
+		return err
+	}
+	log.Print(output)
+	if db != nil {
+		logCatalogError(spec.Name, recordTopicCreated(db, c, spec))
+	}
+	return nil
+}
+
+// deleteTopicInPod executes the command in the pod to delete a Kafka topic
+func deleteTopicInPod(c *Cluster, topicName string) error {
+	if !resourceName.MatchString(topicName) {
+		return fmt.Errorf("invalid topic name %q", topicName)
 	}
+	_, err := execInPod(c, fmt.Sprintf("kafka-topics.sh --delete --topic %s %s", topicPattern(topicName), bootstrapArg))
+	return err
+}
+
+// execInPod runs a shell command in the Kafka container of the cluster's broker pod and returns its stdout
+func execInPod(c *Cluster, command string) (string, error) {
+	return execInPodWithStdin(c, command, nil)
+}
+
+// execInPodWithStdin is execInPod with stdin attached, for input that must not appear on the command line.
+// Tests replace it to check the commands sent to the pod.
+var execInPodWithStdin = streamInPod
+
+// streamInPod runs a command in the pod through the exec subresource
+func streamInPod(c *Cluster, command string, stdin io.Reader) (string, error) {
+	cmd := []string{"/bin/sh", "-c", command}
 
 	req := clientset.CoreV1().RESTClient().Post().
 		Resource("pods").
-		Name(podName).
-		Namespace(podNamespace).
+		Name(c.Pod).
+		Namespace(c.Namespace).
 		SubResource("exec").
 		Param("container", "kafka").
-		Param("stdin", "true").
 		Param("stdout", "true").
-		Param("stderr", "true").
-		Param("tty", "true")
+		Param("stderr", "true")
+	if stdin != nil {
+		req.Param("stdin", "true")
+	}
 
 	for _, arg := range cmd {
 		req.Param("command", arg)
 	}
 
-	exec, err := exec.NewSPDYExecutor(clientset.RESTConfig(), "POST", req.URL())
+	executor, err := remotecommand.NewSPDYExecutor(restConfig, "POST", req.URL())
 	if err != nil {
-		return fmt.Errorf("failed to create executor: %w", err)
+		return "", fmt.Errorf("failed to create executor: %w", err)
 	}
 
-	err = exec.Stream(exec.StreamOptions{
-		IOStreams: exec.IOStreams{
-			Out: os.Stdout,
-			ErrOut: os.Stderr,
-		},
-		Tty: false,
+	// Capture output
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	err = executor.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+		Tty:    false,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to execute command in pod: %w", err)
+		return "", fmt.Errorf("failed to execute command in pod: %w: %s", err, strings.TrimSpace(stderr.String()))
 	}
 
-	return nil
+	return stdout.String(), nil
 }
 
 
-
 go run main.go -kubeconfig=/path/to/kubeconfig -namespace=kafka-namespace -pod=kafka-dev-0
 
+go run main.go -kubeconfig=$HOME/.kube/config -context aks-kafka-test -as kafka-admin -namespace=kafka-test -pod=kafka-test-0
+
+go run main.go -kubeconfig=/path/to/kubeconfig -db "host=localhost dbname=mydatabase sslmode=disable" drift -format csv
+
+go run main.go -kubeconfig=/path/to/kubeconfig backup -o dev-before-upgrade.yaml
+go run main.go -kubeconfig=/path/to/kubeconfig -clusters clusters.yaml restore -cluster test -f dev-before-upgrade.yaml -dry-run
@@ -16,12 +16,13 @@ var logger = logrus.New()
 
 // DBWrapper wraps the database connection and provides additional functionality.
 type DBWrapper struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Driver string // driver name passed to sql.Open
 }
 
 // Config represents database configuration.
 type Config struct {
-	Driver   string `yaml:"driver"`   // Database driver (e.g., "postgres", "mysql", "sqlite3")
+	Driver   string `yaml:"driver"`   // Database driver (e.g., "postgres", "mysql", "sqlite3" or "sqlite")
 	Host     string `yaml:"host"`     // Hostname or IP
 	Port     int    `yaml:"port"`     // Port number
 	User     string `yaml:"user"`     // Username
@@ -33,6 +34,7 @@ type Config struct {
 // NewDB initializes a database connection using the provided config.
 func NewDB(cfg Config) (*DBWrapper, error) {
 	var dsn string
+	driver := cfg.Driver
 
 	switch cfg.Driver {
 	case "postgres":
@@ -41,13 +43,16 @@ func NewDB(cfg Config) (*DBWrapper, error) {
 	case "mysql":
 		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
 			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName)
-	case "sqlite3":
-		dsn = cfg.DBName // SQLite uses only the database file path as DSN
+	case "sqlite3", "sqlite":
+		// SQLite uses only the database file path as DSN; modernc.org/sqlite registers as "sqlite"
+		// and leaves foreign keys off unless asked
+		dsn = cfg.DBName + "?_pragma=foreign_keys(1)"
+		driver = "sqlite"
 	default:
 		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
 	}
 
-	db, err := sql.Open(cfg.Driver, dsn)
+	db, err := sql.Open(driver, dsn)
 	if err != nil {
 		logger.Error("Failed to connect to database: ", err)
 		return nil, err
@@ -60,7 +65,7 @@ func NewDB(cfg Config) (*DBWrapper, error) {
 	}
 
 	logger.Info("Database connection established")
-	return &DBWrapper{DB: db}, nil
+	return &DBWrapper{DB: db, Driver: driver}, nil
 }
 
 // Query executes a SELECT query.
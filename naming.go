@@ -0,0 +1,226 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// NamingPolicy is the layout of the -naming-policy YAML file, e.g.
+//
+//	maxLength: 120
+//	format: "<domain>.<env>.<dataset>"
+//	segments:
+//	  env: [dev, test, prod]
+//	patterns:
+//	  - pattern: '^[a-z0-9._-]+$'
+//	    message: use lower-case letters, digits, dots, dashes and underscores
+//	forbiddenWords: [tmp, temp, test]
+//
+// The <domain> segment must name a row in data_domains when -db is set.
+type NamingPolicy struct {
+	MaxLength      int                 `yaml:"maxLength"`
+	Format         string              `yaml:"format"`   // segments in angle brackets, literal separators between them
+	Segments       map[string][]string `yaml:"segments"` // allowed values per segment; unlisted segments take any value
+	Patterns       []NamingPattern     `yaml:"patterns"`
+	ForbiddenWords []string            `yaml:"forbiddenWords"` // matched case-insensitively against the words of the name
+
+	format   *regexp.Regexp
+	patterns []*regexp.Regexp
+}
+
+// NamingPattern is a regular expression every topic name must match.
+type NamingPattern struct {
+	Pattern string `yaml:"pattern"`
+	Message string `yaml:"message"` // shown instead of the pattern when the name does not match
+}
+
+// Violation is one rule a topic name breaks.
+type Violation struct {
+	Rule    string `json:"rule"` // maxLength, format, segment, domain, pattern or forbiddenWord
+	Message string `json:"message"`
+}
+
+var namingPolicy *NamingPolicy
+
+var formatSegment = regexp.MustCompile(`<([A-Za-z0-9_]+)>`)
+
+// loadNamingPolicy reads and compiles the naming policy from a YAML file.
+func loadNamingPolicy(file string) (*NamingPolicy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &NamingPolicy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// compile turns the format and patterns into regular expressions
+func (p *NamingPolicy) compile() error {
+	if p.Format != "" {
+		var expr strings.Builder
+		expr.WriteString("^")
+		last := 0
+		for _, m := range formatSegment.FindAllStringSubmatchIndex(p.Format, -1) {
+			expr.WriteString(regexp.QuoteMeta(p.Format[last:m[0]]))
+			fmt.Fprintf(&expr, "(?P<%s>[A-Za-z0-9_-]+)", p.Format[m[2]:m[3]])
+			last = m[1]
+		}
+		expr.WriteString(regexp.QuoteMeta(p.Format[last:]) + "$")
+		re, err := regexp.Compile(expr.String())
+		if err != nil {
+			return fmt.Errorf("invalid format %q: %w", p.Format, err)
+		}
+		p.format = re
+	}
+	for seg := range p.Segments {
+		if p.format == nil || p.format.SubexpIndex(seg) < 0 {
+			return fmt.Errorf("segment %q is not part of the format", seg)
+		}
+	}
+	for _, np := range p.Patterns {
+		re, err := regexp.Compile(np.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", np.Pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	return nil
+}
+
+// Validate returns every rule the topic name breaks. The domain segment is checked
+// against data_domains when db is not nil; an error means the check could not run.
+func (p *NamingPolicy) Validate(db *sql.DB, name string) ([]Violation, error) {
+	violations := []Violation{}
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{rule, fmt.Sprintf(format, args...)})
+	}
+
+	if !resourceName.MatchString(name) {
+		add("pattern", "topic names may only contain letters, digits, dots, dashes and underscores")
+	}
+	if p.MaxLength > 0 && len(name) > p.MaxLength {
+		add("maxLength", "name is %d characters long, the limit is %d", len(name), p.MaxLength)
+	}
+	for i, re := range p.patterns {
+		if !re.MatchString(name) {
+			if msg := p.Patterns[i].Message; msg != "" {
+				add("pattern", "%s", msg)
+			} else {
+				add("pattern", "name must match %s", re)
+			}
+		}
+	}
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), isNameSeparator) {
+		for _, forbidden := range p.ForbiddenWords {
+			if word == strings.ToLower(forbidden) {
+				add("forbiddenWord", "%q is not allowed in topic names", word)
+			}
+		}
+	}
+
+	if p.format != nil {
+		m := p.format.FindStringSubmatch(name)
+		if m == nil {
+			add("format", "name must have the form %s", p.Format)
+			return violations, nil
+		}
+		for _, seg := range p.format.SubexpNames()[1:] {
+			allowed, ok := p.Segments[seg]
+			value := m[p.format.SubexpIndex(seg)]
+			if ok && !containsString(allowed, value) {
+				add("segment", "%s %q must be one of %s", seg, value, strings.Join(allowed, ", "))
+			}
+		}
+		if i := p.format.SubexpIndex("domain"); i >= 0 && db != nil {
+			var exists bool
+			err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM data_domains WHERE domain_name = $1)", m[i]).Scan(&exists)
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up data domain: %w", err)
+			}
+			if !exists {
+				add("domain", "data domain %q does not exist", m[i])
+			}
+		}
+	}
+	return violations, nil
+}
+
+func isNameSeparator(r rune) bool {
+	return r == '.' || r == '-' || r == '_'
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTopicName checks a name against the configured policy; without a policy only
+// the characters are checked
+func validateTopicName(name string) ([]Violation, error) {
+	p := namingPolicy
+	if p == nil {
+		p = &NamingPolicy{}
+	}
+	return p.Validate(db, name)
+}
+
+// violationMessage joins violations into one error message
+func violationMessage(violations []Violation) string {
+	msgs := make([]string, len(violations))
+	for i, v := range violations {
+		msgs[i] = v.Message
+	}
+	return "Topic name violates the naming policy: " + strings.Join(msgs, "; ")
+}
+
+// validateTopicRequest is the body of POST /topics/validate.
+type validateTopicRequest struct {
+	TopicName string `json:"topicName"`
+}
+
+// topicValidation is the result of checking a topic name against the naming policy.
+type topicValidation struct {
+	TopicName  string      `json:"topicName"`
+	Valid      bool        `json:"valid"`
+	Violations []Violation `json:"violations"`
+}
+
+// handleValidateTopic handles requests to the /topics/validate endpoint
+func handleValidateTopic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var reqBody validateTopicRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.TopicName == "" {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	violations, err := validateTopicName(reqBody.TopicName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, topicValidation{
+		TopicName:  reqBody.TopicName,
+		Valid:      len(violations) == 0,
+		Violations: violations,
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// openAPISpec is the contract of the REST API, served at /openapi.json.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// errorCodes maps HTTP statuses to the machine-readable codes of the Error schema.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusNotFound:            "not_found",
+	http.StatusMethodNotAllowed:    "method_not_allowed",
+	http.StatusUnprocessableEntity: "unprocessable",
+	http.StatusTooManyRequests:     "too_many_requests",
+	http.StatusInternalServerError: "internal",
+	http.StatusBadGateway:          "bad_gateway",
+	http.StatusServiceUnavailable:  "unavailable",
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an Error body whose code is derived from the status
+func writeError(w http.ResponseWriter, status int, message string) {
+	var body apiError
+	body.Error.Code = errorCodes[status]
+	if body.Error.Code == "" {
+		body.Error.Code = "internal"
+	}
+	body.Error.Message = message
+	writeJSON(w, status, body)
+}
+
+// handleOpenAPI serves the embedded OpenAPI document
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// checkSpecRoutes sends every operation of the embedded OpenAPI document through mux, with
+// path parameters filled in from their examples, and returns an error listing each operation
+// that no route serves and each route path, method or handler the document does not describe.
+func checkSpecRoutes(mux *http.ServeMux) error {
+	ops, err := specOperations()
+	if err != nil {
+		return err
+	}
+	byPattern := map[string]map[string][]string{}
+	for _, rt := range routes {
+		byPattern[rt.pattern] = rt.paths
+	}
+
+	var diffs []string
+	documented := map[string]bool{}
+	reached := map[string]bool{}
+	for _, op := range ops {
+		documented[op.Method+" "+op.Path] = true
+		req, err := http.NewRequest(op.Method, op.URL, nil)
+		if err != nil {
+			return fmt.Errorf("invalid path %s in openapi.json: %w", op.Path, err)
+		}
+		_, pattern := mux.Handler(req)
+		if pattern == "/" && op.Path != "/" {
+			diffs = append(diffs, "no handler for "+op.Method+" "+op.Path)
+			continue
+		}
+		reached[pattern] = true
+		if !containsString(byPattern[pattern][op.Path], op.Method) {
+			diffs = append(diffs, fmt.Sprintf("%s %s is routed to %s, which does not list it", op.Method, op.Path, pattern))
+		}
+	}
+	for _, rt := range routes {
+		if !reached[rt.pattern] {
+			diffs = append(diffs, "handler not in spec: "+rt.pattern)
+		}
+		for path, methods := range rt.paths {
+			for _, method := range methods {
+				if !documented[method+" "+path] {
+					diffs = append(diffs, "not in spec: "+method+" "+path)
+				}
+			}
+		}
+	}
+	if len(diffs) > 0 {
+		sort.Strings(diffs)
+		return fmt.Errorf("routes and openapi.json diverge:\n  %s", strings.Join(diffs, "\n  "))
+	}
+	return nil
+}
+
+// specOperation is one method on one path of the OpenAPI document.
+type specOperation struct {
+	Method      string // upper case
+	Path        string // as documented, e.g. /topics/{name}
+	URL         string // Path with every parameter replaced by its example
+	OperationID string
+}
+
+// specOperations lists the operations of the embedded OpenAPI document sorted by path and method
+func specOperations() ([]specOperation, error) {
+	type param struct {
+		Name    string      `json:"name"`
+		In      string      `json:"in"`
+		Example interface{} `json:"example"`
+	}
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		return nil, fmt.Errorf("invalid openapi.json: %w", err)
+	}
+
+	var ops []specOperation
+	for path, item := range spec.Paths {
+		var shared []param
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("invalid parameters of %s: %w", path, err)
+			}
+		}
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			var op struct {
+				OperationID string  `json:"operationId"`
+				Parameters  []param `json:"parameters"`
+			}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("invalid %s %s: %w", method, path, err)
+			}
+			u := path
+			for _, p := range append(shared, op.Parameters...) {
+				if p.In == "path" {
+					example := p.Name
+					if p.Example != nil {
+						example = fmt.Sprint(p.Example)
+					}
+					u = strings.ReplaceAll(u, "{"+p.Name+"}", url.PathEscape(example))
+				}
+			}
+			ops = append(ops, specOperation{Method: strings.ToUpper(method), Path: path, URL: u, OperationID: op.OperationID})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops, nil
+}
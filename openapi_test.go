@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSpecMatchesRoutes(t *testing.T) {
+	if err := checkSpecRoutes(newMux()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSpecOperationsReachHandlers sends every documented operation through the real mux and
+// handlers. The cluster is unknown, so no handler gets as far as exec'ing into a pod; what
+// matters is that no operation falls through to the catch-all 404 or is refused with a 405.
+func TestSpecOperationsReachHandlers(t *testing.T) {
+	ops, err := specOperations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := newMux()
+	for _, op := range ops {
+		t.Run(op.Method+" "+op.Path, func(t *testing.T) {
+			var body *strings.Reader
+			if op.Method == "POST" || op.Method == "PUT" || op.Method == "PATCH" {
+				body = strings.NewReader("{}")
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(op.Method, op.URL+"?cluster=spec-test", body)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code == http.StatusMethodNotAllowed {
+				t.Fatalf("%s %s: handler does not accept the method", op.Method, op.URL)
+			}
+			if rec.Code == http.StatusNotFound {
+				var e apiError
+				if json.Unmarshal(rec.Body.Bytes(), &e) == nil && e.Error.Message == "Not found" {
+					t.Fatalf("%s %s: no handler serves the path", op.Method, op.URL)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownPathIsNotFound(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newMux().ServeHTTP(rec, httptest.NewRequest("GET", "/no/such/path", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+// schemaTypes maps every schema in openapi.json to the value the handlers decode or encode for it.
+var schemaTypes = map[string]interface{}{
+	"Error":              apiError{},
+	"CreateTopicRequest": createTopicRequest{},
+	"TopicSpec":          TopicSpec{},
+	"CatalogEntry":       CatalogEntry{},
+	"CatalogRecord":      CatalogRecord{},
+	"CatalogReport":      CatalogReport{},
+	"Violation":          Violation{},
+	"TopicValidation":    topicValidation{},
+	"PartitionState":     PartitionState{},
+	"TopicDetail":        TopicDetail{},
+	"GroupTopicLag":      GroupTopicLag{},
+	"ConsumerGroup":      ConsumerGroup{},
+	"ACL":                ACL{},
+	"ApplyResult":        applyResult{},
+	"DriftReport":        DriftReport{},
+	"KafkaUser":          kafkaUser{},
+	"Credential":         credential{},
+	"Quotas":             quotaKeys, // PUT /quotas decodes into a map checked against these keys
+	"ProblemPartition":   problemPartition{},
+	"PartitionHealth":    partitionHealth{},
+	"ConnectorRequest":   connectorRequest{},
+	"Connector":          connectorView{},
+}
+
+// inlineBodyTypes maps the operations whose request body schema is declared inline.
+var inlineBodyTypes = map[string]interface{}{
+	"validateTopic": validateTopicRequest{},
+}
+
+// specRefs matches the schema name of every $ref in openapi.json.
+var specRefs = regexp.MustCompile(`"#/components/schemas/([^"]+)"`)
+
+type specSchema struct {
+	Ref        string                     `json:"$ref"`
+	Type       string                     `json:"type"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	AllOf      []specSchema               `json:"allOf"`
+}
+
+// properties returns the sorted property names of a schema, following $ref and allOf
+func (sc specSchema) properties(schemas map[string]specSchema) []string {
+	if sc.Ref != "" {
+		return schemas[strings.TrimPrefix(sc.Ref, "#/components/schemas/")].properties(schemas)
+	}
+	var names []string
+	for name := range sc.Properties {
+		names = append(names, name)
+	}
+	for _, part := range sc.AllOf {
+		names = append(names, part.properties(schemas)...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// jsonFields returns the sorted JSON names of a struct's fields, embedded structs included;
+// for a map it returns the keys
+func jsonFields(v interface{}) []string {
+	var names []string
+	if m := reflect.ValueOf(v); m.Kind() == reflect.Map {
+		for _, k := range m.MapKeys() {
+			names = append(names, k.String())
+		}
+		sort.Strings(names)
+		return names
+	}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if f.Anonymous && tag == "" {
+				walk(f.Type)
+				continue
+			}
+			if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+				names = append(names, name)
+			}
+		}
+	}
+	walk(reflect.TypeOf(v))
+	sort.Strings(names)
+	return names
+}
+
+// TestSchemasMatchHandlerTypes checks every request and response field: each schema in
+// openapi.json, and each inline request body, has exactly the JSON fields of the Go type the
+// handlers use for it, and every schema an operation refers to is covered.
+func TestSchemasMatchHandlerTypes(t *testing.T) {
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]specSchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+	schemas := spec.Components.Schemas
+
+	for name, sc := range schemas {
+		v, ok := schemaTypes[name]
+		if !ok {
+			t.Errorf("schema %s has no handler type in schemaTypes", name)
+			continue
+		}
+		if got, want := jsonFields(v), sc.properties(schemas); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %T has JSON fields %v, schema has %v", name, v, got, want)
+		}
+	}
+	for name := range schemaTypes {
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("schemaTypes lists %s, which is not in openapi.json", name)
+		}
+	}
+
+	for path, item := range spec.Paths {
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			var op struct {
+				OperationID string `json:"operationId"`
+				RequestBody struct {
+					Content map[string]struct {
+						Schema specSchema `json:"schema"`
+					} `json:"content"`
+				} `json:"requestBody"`
+			}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+			body, ok := op.RequestBody.Content["application/json"]
+			if !ok || body.Schema.Properties == nil {
+				continue
+			}
+			v, ok := inlineBodyTypes[op.OperationID]
+			if !ok {
+				t.Errorf("%s %s: inline request body has no type in inlineBodyTypes", strings.ToUpper(method), path)
+				continue
+			}
+			if got, want := jsonFields(v), body.Schema.properties(schemas); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %T has JSON fields %v, request body has %v", op.OperationID, v, got, want)
+			}
+		}
+	}
+	// every $ref must point at a schema, so the checks above cover every body
+	for _, ref := range specRefs.FindAllStringSubmatch(string(openAPISpec), -1) {
+		if _, ok := schemas[ref[1]]; !ok {
+			t.Errorf("$ref to unknown schema %s", ref[1])
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+var (
+	rateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_topic_service_rate_limited_total",
+		Help: "Requests rejected with 429 because the client exceeded its rate limit. The client is the API token name, anonymous when authentication is off, or unauthenticated for addresses with too many failed authentications.",
+	}, []string{"client"})
+	execRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_topic_service_exec_rejected_total",
+		Help: "Requests rejected with 429 because the broker pod had no free exec slot.",
+	}, []string{"pod"})
+	execInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_topic_service_exec_in_flight",
+		Help: "Requests currently holding an exec slot on a broker pod.",
+	}, []string{"pod"})
+	execWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_topic_service_exec_wait_seconds",
+		Help:    "Time requests waited for an exec slot on a broker pod.",
+		Buckets: []float64{0.001, 0.01, 0.1, 0.5, 1, 2, 5},
+	}, []string{"pod"})
+)
+
+func init() {
+	prometheus.MustRegister(rateLimitedTotal, execRejectedTotal, execInFlight, execWaitSeconds)
+}
+
+// clientLimiter keeps one token bucket per client.
+type clientLimiter struct {
+	rate  rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*clientBucket
+}
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+var limiter = &clientLimiter{rate: 5, burst: 10, limiters: map[string]*clientBucket{}}
+
+// authFailures allows each remote address a burst of failed authentications and one more
+// every 10 seconds after that; withAuth refuses an address whose bucket is empty.
+var authFailures = &clientLimiter{rate: 0.1, burst: 10, limiters: map[string]*clientBucket{}}
+
+// allow takes a token for the client, or reports how long until one is available.
+func (l *clientLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	b, ok := l.limiters[client]
+	if !ok {
+		b = &clientBucket{limiter: rate.NewLimiter(l.rate, l.burst)}
+		l.limiters[client] = b
+	}
+	b.lastSeen = time.Now()
+	l.mu.Unlock()
+
+	res := b.limiter.Reserve()
+	if delay := res.Delay(); delay > 0 {
+		res.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// exhausted reports, without taking a token, whether the client's bucket is empty and how long
+// until it holds a token again.
+func (l *clientLimiter) exhausted(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	b, ok := l.limiters[client]
+	l.mu.Unlock()
+	if !ok {
+		return false, 0
+	}
+	if tokens := b.limiter.Tokens(); tokens < 1 {
+		return true, time.Duration((1 - tokens) / float64(l.rate) * float64(time.Second))
+	}
+	return false, 0
+}
+
+// sweep forgets clients that have been idle for longer than idle.
+func (l *clientLimiter) sweep(idle time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for client, b := range l.limiters {
+		if time.Since(b.lastSeen) > idle {
+			delete(l.limiters, client)
+		}
+	}
+}
+
+// withRateLimit answers 429 with Retry-After once a client exceeds its token bucket.
+func withRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" || publicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client := requestClient(r)
+		if ok, delay := limiter.allow(client); !ok {
+			rateLimitedTotal.WithLabelValues(metricClient(r)).Inc()
+			tooManyRequests(w, delay, "Rate limit exceeded for "+client)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// metricClient returns the client label for metrics: the token name, which comes from the
+// bounded token file, or "anonymous" instead of the remote address when auth is disabled.
+func metricClient(r *http.Request) string {
+	if client, ok := r.Context().Value(clientKey{}).(string); ok {
+		return client
+	}
+	return "anonymous"
+}
+
+// podSlots caps the number of concurrent requests exec'ing into each broker pod.
+type podSlots struct {
+	max  int
+	wait time.Duration
+
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+var execSlots = &podSlots{max: 4, wait: 2 * time.Second, sems: map[string]chan struct{}{}}
+
+func (p *podSlots) sem(pod string) chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.sems[pod]
+	if !ok {
+		s = make(chan struct{}, p.max)
+		p.sems[pod] = s
+	}
+	return s
+}
+
+// withExecLimit holds an exec slot on the request's broker pod for the duration of the request,
+// waiting up to execSlots.wait before answering 429.
+func withExecLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cluster, err := requestCluster(r)
+		if err != nil {
+			next(w, r) // the handler reports the unknown cluster
+			return
+		}
+		release, ok := acquireExecSlot(w, r, cluster)
+		if !ok {
+			return
+		}
+		defer release()
+		next(w, r)
+	}
+}
+
+// acquireExecSlot takes an exec slot on the cluster's broker pod for handlers that only exec
+// for some methods. It answers 429 and returns false when no slot frees up in time; otherwise
+// the caller must call release.
+func acquireExecSlot(w http.ResponseWriter, r *http.Request, cluster *Cluster) (release func(), ok bool) {
+	pod := cluster.Namespace + "/" + cluster.Pod
+	sem := execSlots.sem(pod)
+
+	ctx, cancel := context.WithTimeout(r.Context(), execSlots.wait)
+	defer cancel()
+	start := time.Now()
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		execRejectedTotal.WithLabelValues(pod).Inc()
+		tooManyRequests(w, time.Second, "Too many concurrent operations on "+pod)
+		return nil, false
+	}
+	execWaitSeconds.WithLabelValues(pod).Observe(time.Since(start).Seconds())
+	execInFlight.WithLabelValues(pod).Inc()
+	return func() {
+		<-sem
+		execInFlight.WithLabelValues(pod).Dec()
+	}, true
+}
+
+// tooManyRequests writes a 429 error with a Retry-After header rounded up to whole seconds
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, message)
+}
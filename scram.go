@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// entityName restricts user and client ids to characters that are safe inside the pod shell command.
+var entityName = regexp.MustCompile(`^[A-Za-z0-9._@=-]+$`)
+
+// describeLine matches kafka-configs.sh --describe output such as
+// "SCRAM credential configs for user-principal 'alice' are SCRAM-SHA-512=iterations=8192"
+var describeLine = regexp.MustCompile(`^(SCRAM credential|Quota) configs for (user-principal|client-id) '([^']*)' are (.*)$`)
+
+const scramIterations = 8192
+
+// quotaKeys maps the JSON quota fields to their kafka-configs.sh names.
+var quotaKeys = map[string]string{
+	"producerByteRate":  "producer_byte_rate",
+	"consumerByteRate":  "consumer_byte_rate",
+	"requestPercentage": "request_percentage",
+}
+
+// kafkaUser is a principal with SCRAM credentials and/or quotas on the cluster.
+type kafkaUser struct {
+	Name       string             `json:"name" yaml:"name"`
+	Mechanisms []string           `json:"mechanisms,omitempty" yaml:"mechanisms,omitempty"`
+	Quotas     map[string]float64 `json:"quotas,omitempty" yaml:"quotas,omitempty"`
+}
+
+// credential is a newly issued SCRAM credential, the only time its password is shown.
+type credential struct {
+	Name      string `json:"name"`
+	Mechanism string `json:"mechanism"`
+	Password  string `json:"password"`
+}
+
+// handleUsers handles requests under /users
+func handleUsers(w http.ResponseWriter, r *http.Request) {
+	cluster, err := requestCluster(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	// /users or /users/{identity}/credentials
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/users"), "/"), "/")
+
+	switch {
+	case parts[0] == "" && r.Method == "GET":
+		users, err := describeUsers(cluster)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list users: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, users)
+
+	case len(parts) == 2 && parts[1] == "credentials" && r.Method == "POST":
+		if !entityName.MatchString(parts[0]) {
+			writeError(w, http.StatusBadRequest, "Invalid identity")
+			return
+		}
+		// With -db, credentials are only issued to registered technical identities
+		if db != nil {
+			var known bool
+			if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM technical_identities WHERE identity = $1)", parts[0]).Scan(&known); err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to look up identity: "+err.Error())
+				return
+			}
+			if !known {
+				writeError(w, http.StatusNotFound, "Unknown technical identity "+parts[0])
+				return
+			}
+		}
+		password, err := upsertScramCredential(cluster, parts[0])
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to set credentials: "+err.Error())
+			return
+		}
+		// The password is only ever returned here; Kafka stores a salted hash
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusCreated, credential{Name: parts[0], Mechanism: "SCRAM-SHA-512", Password: password})
+
+	case len(parts) == 2 && parts[1] == "credentials" && r.Method == "DELETE":
+		if !entityName.MatchString(parts[0]) {
+			writeError(w, http.StatusBadRequest, "Invalid identity")
+			return
+		}
+		if err := alterEntityConfig(cluster, "users", parts[0], "--delete-config SCRAM-SHA-512"); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to delete credentials: "+err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleQuotas handles requests to /quotas/{users|clients}/{name}
+func handleQuotas(w http.ResponseWriter, r *http.Request) {
+	cluster, err := requestCluster(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/quotas"), "/"), "/")
+	if len(parts) != 2 || (parts[0] != "users" && parts[0] != "clients") || !entityName.MatchString(parts[1]) {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	entityType, name := parts[0], parts[1]
+
+	switch r.Method {
+	case "PUT":
+		// Set quotas (expecting JSON payload with any of producerByteRate, consumerByteRate, requestPercentage)
+		var reqBody map[string]float64
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || len(reqBody) == 0 {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		var configs []string
+		for field, value := range reqBody {
+			key, ok := quotaKeys[field]
+			if !ok || value < 0 {
+				writeError(w, http.StatusBadRequest, "Invalid quota "+field)
+				return
+			}
+			configs = append(configs, key+"="+strconv.FormatFloat(value, 'f', -1, 64))
+		}
+		sort.Strings(configs)
+		if err := alterEntityConfig(cluster, entityType, name, "--add-config "+strings.Join(configs, ",")); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to set quotas: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, reqBody)
+
+	case "DELETE":
+		// Remove the quotas named in ?quota=, or all of them
+		keys := r.URL.Query()["quota"]
+		if len(keys) == 0 {
+			for field := range quotaKeys {
+				keys = append(keys, field)
+			}
+		}
+		var configs []string
+		for _, field := range keys {
+			key, ok := quotaKeys[field]
+			if !ok {
+				writeError(w, http.StatusBadRequest, "Invalid quota "+field)
+				return
+			}
+			configs = append(configs, key)
+		}
+		sort.Strings(configs)
+		if err := alterEntityConfig(cluster, entityType, name, "--delete-config "+strings.Join(configs, ",")); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to remove quotas: "+err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// upsertScramCredential creates or rotates the SCRAM-SHA-512 credential of a user and returns the new password.
+// The password goes to kafka-configs.sh through a private temporary file filled from stdin, so it never
+// appears in the process list of the broker pod.
+func upsertScramCredential(c *Cluster, user string) (string, error) {
+	password, err := randomPassword(32)
+	if err != nil {
+		return "", err
+	}
+	// kafka-configs.sh only strips the [...] around values given inline with --add-config;
+	// a properties file value is parsed as is
+	config := fmt.Sprintf("SCRAM-SHA-512=iterations=%d,password=%s\n", scramIterations, password)
+	if err := alterEntityConfigFile(c, "users", user, config); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// alterEntityConfig runs kafka-configs.sh --alter for a user or client entity
+func alterEntityConfig(c *Cluster, entityType, name, change string) error {
+	_, err := execInPod(c, fmt.Sprintf("kafka-configs.sh %s --alter --entity-type %s --entity-name %s %s",
+		bootstrapArg, entityType, name, change))
+	return err
+}
+
+// alterEntityConfigFile runs kafka-configs.sh --alter --add-config-file with properties sent over stdin
+// into a private temporary file, so neither secrets nor shell quoting end up on the command line
+func alterEntityConfigFile(c *Cluster, entityType, name, properties string) error {
+	command := fmt.Sprintf(`f=$(mktemp) && trap 'rm -f "$f"' EXIT && cat > "$f" && `+
+		`kafka-configs.sh %s --alter --entity-type %s --entity-name %s --add-config-file "$f"`, bootstrapArg, entityType, name)
+	_, err := execInPodWithStdin(c, command, strings.NewReader(properties))
+	return err
+}
+
+// describeUsers lists every user principal with SCRAM credentials or quotas
+func describeUsers(c *Cluster) ([]kafkaUser, error) {
+	return describeEntities(c, "users")
+}
+
+// describeEntities lists every user or client entity with configs set through kafka-configs.sh
+func describeEntities(c *Cluster, entityType string) ([]kafkaUser, error) {
+	output, err := execInPod(c, "kafka-configs.sh "+bootstrapArg+" --describe --entity-type "+entityType)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*kafkaUser{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		m := describeLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		u, ok := byName[m[3]]
+		if !ok {
+			u = &kafkaUser{Name: m[3]}
+			byName[m[3]] = u
+		}
+		for _, kv := range strings.Split(m[4], ", ") {
+			key, value, _ := strings.Cut(kv, "=")
+			if m[1] == "SCRAM credential" {
+				u.Mechanisms = append(u.Mechanisms, key)
+				continue
+			}
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				if u.Quotas == nil {
+					u.Quotas = map[string]float64{}
+				}
+				u.Quotas[key] = f
+			}
+		}
+	}
+
+	users := make([]kafkaUser, 0, len(byName))
+	for _, u := range byName {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+// randomPassword returns an alphanumeric password, which needs no quoting in the pod shell
+func randomPassword(n int) (string, error) {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// podCall is one command sent to the broker pod, with what it read from stdin
+type podCall struct {
+	command, stdin string
+}
+
+// recordPodExec replaces execInPodWithStdin for the test; every call returns output
+func recordPodExec(t *testing.T, output string) *[]podCall {
+	t.Helper()
+	var calls []podCall
+	saved := execInPodWithStdin
+	execInPodWithStdin = func(c *Cluster, command string, stdin io.Reader) (string, error) {
+		call := podCall{command: command}
+		if stdin != nil {
+			data, err := io.ReadAll(stdin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			call.stdin = string(data)
+		}
+		calls = append(calls, call)
+		return output, nil
+	}
+	t.Cleanup(func() { execInPodWithStdin = saved })
+	return &calls
+}
+
+var testCluster = &Cluster{Name: "test", Namespace: "kafka", Pod: "kafka-0"}
+
+func TestUpsertScramCredentialStdin(t *testing.T) {
+	calls := recordPodExec(t, "")
+	password, err := upsertScramCredential(testCluster, "app1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("%d exec calls, want 1", len(*calls))
+	}
+	call := (*calls)[0]
+	if want := "SCRAM-SHA-512=iterations=8192,password=" + password + "\n"; call.stdin != want {
+		t.Errorf("stdin = %q, want %q", call.stdin, want)
+	}
+	if !regexp.MustCompile(`^[A-Za-z0-9]{32}$`).MatchString(password) {
+		t.Errorf("password %q is not 32 alphanumeric characters", password)
+	}
+	if strings.Contains(call.command, password) {
+		t.Error("password appears on the command line")
+	}
+	if !strings.Contains(call.command, "--entity-type users --entity-name app1 --add-config-file \"$f\"") {
+		t.Errorf("command = %q", call.command)
+	}
+}
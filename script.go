@@ -22,23 +22,106 @@ CREATE TABLE data_domain_identities (
     FOREIGN KEY (domain_name) REFERENCES data_domains(domain_name)
 );
 
+-- Create table for the topic ownership catalog, one entry per topic and cluster
+CREATE TABLE topic_catalog (
+    cluster VARCHAR(255) NOT NULL,
+    topic_name VARCHAR(255) NOT NULL,
+    domain_name VARCHAR(255), -- Owning data domain, when known
+    owner VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    classification VARCHAR(32) NOT NULL DEFAULT '', -- public, internal, confidential or restricted
+    retention_rationale TEXT NOT NULL DEFAULT '',
+    contact VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Automatically stores the insert timestamp
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cluster, topic_name),
+    FOREIGN KEY (domain_name) REFERENCES data_domains(domain_name)
+);
+
+-- Create table for the validity intervals of identity-domain mappings; the ingest tool and the
+-- identity API open a row when a mapping appears and close it when the mapping disappears
+CREATE TABLE data_domain_identity_history (
+    identity VARCHAR(255) NOT NULL,
+    domain_name VARCHAR(255) NOT NULL,
+    valid_from TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    valid_to TIMESTAMP, -- NULL while the mapping exists
+    CHECK (valid_to IS NULL OR valid_to >= valid_from)
+);
+CREATE INDEX data_domain_identity_history_lookup ON data_domain_identity_history (domain_name, identity, valid_from);
+
+-- Seed the history with the mappings that existed before it was introduced
+INSERT INTO data_domain_identity_history (identity, domain_name, valid_from)
+SELECT identity, domain_name, created_at FROM data_domain_identities;
+
+-- Describe who owns each technical identity and until when it may be used; the ingest tool
+-- loads these from the identities section of YAML and JSON input, the identity API from requests
+ALTER TABLE technical_identities
+    ADD COLUMN IF NOT EXISTS owner VARCHAR(255) NOT NULL DEFAULT '', -- Person or mailbox answerable for the identity
+    ADD COLUMN IF NOT EXISTS team VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS environment VARCHAR(32) NOT NULL DEFAULT '', -- e.g. dev, test or prod
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS expires_at DATE; -- The identity is expired from this date on; NULL never expires
+
+-- Create table for the audit trail of changes made through the identity API
+CREATE TABLE identity_audit (
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Automatically stores the change timestamp
+    actor VARCHAR(255) NOT NULL, -- Authenticated user or remote address
+    action VARCHAR(16) NOT NULL, -- create, update or delete
+    entity VARCHAR(32) NOT NULL, -- identity, domain or mapping
+    entity_key VARCHAR(511) NOT NULL -- Identity, domain or domain/identity
+);
+
 package main
 
 import (
+	"bufio"
 	"database/sql"
+	"encoding/base64"
 	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v2"
 
-	_ "github.com/lib/pq"
+	"path/to/your/dbutils" // Update this with your actual package path
 )
 
 func main() {
-	filePath := flag.String("f", "", "Path to the security.list file")
+	filePath := flag.String("f", "", "Path to the identity mapping file")
+	format := flag.String("format", "", "Input format: csv (security.list), yaml, json or ldif; guessed from the file extension when empty")
+	configFile := flag.String("config", "", "Path to the database YAML config; PG* environment variables override it")
+	syncMode := flag.Bool("sync", false, "Make the database match the file: also remove mappings that are no longer listed")
+	pruneOrphans := flag.Bool("prune-orphans", false, "With -sync, also remove identities and domains left without mappings")
+	dryRun := flag.Bool("dry-run", false, "Print the changes without applying them")
+	rejectFile := flag.String("reject-file", "", "File receiving rejected lines (default <file>.rejects)")
+	strict := flag.Bool("strict", false, "Fail without loading anything if any line is rejected")
 	flag.Parse()
 
+	// Subcommands read from the database instead of loading a file
+	switch flag.Arg(0) {
+	case "":
+	case "export":
+		os.Exit(runExport(*configFile, flag.Args()[1:]))
+	case "query":
+		os.Exit(runQuery(*configFile, flag.Args()[1:]))
+	case "expire":
+		os.Exit(runExpire(*configFile, flag.Args()[1:]))
+	default:
+		fmt.Printf("Unknown command %q\n", flag.Arg(0))
+		os.Exit(1)
+	}
+
 	if *filePath == "" {
 		fmt.Println("Please provide a file path with the -f flag.")
 		return
@@ -51,70 +134,1225 @@ func main() {
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	reader, err := readerFor(*format, *filePath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	entries, metadata, rejects := reader.Read(file)
+	identities, domains, mappings, invalid := validateEntries(entries)
+	rejects = append(rejects, invalid...)
+	described, invalid := validateMetadata(metadata)
+	rejects = append(rejects, invalid...)
+	for identity := range described {
+		identities[identity] = struct{}{}
+	}
+	if len(rejects) > 0 {
+		sort.Slice(rejects, func(i, j int) bool { return rejects[i].line < rejects[j].line })
+		for _, r := range rejects {
+			fmt.Printf("Rejected line %d: %s\n", r.line, r.reason)
+		}
+		path := *rejectFile
+		if path == "" {
+			path = *filePath + ".rejects"
+		}
+		if err := writeRejects(path, rejects); err != nil {
+			fmt.Println("Error writing reject file:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d lines rejected, see %s\n", len(rejects), path)
+		if *strict {
+			fmt.Println("Nothing was loaded because -strict is set.")
+			os.Exit(1)
+		}
+	}
+
+	db, err := openDatabase(*configFile)
+	if err != nil {
+		fmt.Println("Error connecting to database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	current, err := loadSecurityState(db)
+	if err != nil {
+		fmt.Println("Error reading database:", err)
+		os.Exit(1)
+	}
+	plan := planSync(identities, domains, mappings, described, current, *syncMode, *pruneOrphans, time.Now().Format("2006-01-02"))
+	if *syncMode || *dryRun {
+		plan.print(os.Stdout)
+		if *dryRun {
+			return
+		}
+		result, err := plan.apply(db)
+		if err != nil {
+			fmt.Println("Error applying changes, nothing was changed:", err)
+			os.Exit(1)
+		}
+		result.print(os.Stdout)
+		fmt.Println("Database synchronized successfully!")
+		return
+	}
+
+	// Without -sync nothing is removed: insert whatever is missing in one transaction
+	plan.printSkipped(os.Stdout)
+	result, err := plan.apply(db)
+	if err != nil {
+		fmt.Println("Error loading data, nothing was inserted:", err)
+		os.Exit(1)
+	}
+	result.print(os.Stdout)
+	fmt.Println("Data inserted successfully!")
+}
+
+// openDatabase connects with the shared YAML config, or the PG* environment when configFile is empty
+func openDatabase(configFile string) (*sql.DB, error) {
+	cfg, err := dbutils.LoadConfig(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	conn, err := dbutils.ConnectDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return conn.DB, nil
+}
+
+// mapping is one identity-to-domain row of data_domain_identities.
+type mapping struct {
+	domain, identity string
+}
+
+// securityState is the content of the three identity tables, plus the domains the topic
+// catalog refers to, which cannot be deleted.
+type securityState struct {
+	identities     map[string]struct{}
+	domains        map[string]struct{}
+	mappings       map[mapping]struct{}
+	metadata       map[string]identityMetadata
+	catalogDomains map[string]struct{}
+}
+
+// loadSecurityState reads the identity tables
+func loadSecurityState(db *sql.DB) (*securityState, error) {
+	st := &securityState{
+		identities:     make(map[string]struct{}),
+		domains:        make(map[string]struct{}),
+		mappings:       make(map[mapping]struct{}),
+		metadata:       make(map[string]identityMetadata),
+		catalogDomains: make(map[string]struct{}),
+	}
+	if err := loadMetadata(db, "", func(m identityMetadata) {
+		st.identities[m.Identity] = struct{}{}
+		st.metadata[m.Identity] = m
+	}); err != nil {
+		return nil, err
+	}
+	if err := scanStrings(db, "SELECT domain_name FROM data_domains", st.domains); err != nil {
+		return nil, err
+	}
+	if err := scanStrings(db, "SELECT DISTINCT domain_name FROM topic_catalog WHERE domain_name IS NOT NULL", st.catalogDomains); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT domain_name, identity FROM data_domain_identities")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m mapping
+		if err := rows.Scan(&m.domain, &m.identity); err != nil {
+			return nil, err
+		}
+		st.mappings[m] = struct{}{}
+	}
+	return st, rows.Err()
+}
+
+func scanStrings(db *sql.DB, query string, into map[string]struct{}) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return err
+		}
+		into[v] = struct{}{}
+	}
+	return rows.Err()
+}
+
+// syncPlan lists the rows to insert into and delete from the identity tables.
+type syncPlan struct {
+	addIdentities, addDomains       []string
+	addMappings, removeMappings     []mapping
+	removeIdentities, removeDomains []string
+	setMetadata                     []identityMetadata
+	keptDomains                     []string  // orphaned, but still referenced by the topic catalog
+	skippedMappings                 []mapping // listed, but the identity has expired
+}
+
+// planSync compares the file with the database. Removals are only planned in sync mode;
+// identities and domains are only removed when pruneOrphans is set and nothing maps to them anymore,
+// and domains only when no catalogued topic belongs to them. Metadata is only changed for the identities the file describes.
+// Mappings of identities expired on today (YYYY-MM-DD), by the file's expiry or else the database's,
+// are skipped so a load does not bring back what expire revoked; in sync mode they are removed.
+func planSync(identities, domains map[string]struct{}, mappings map[string]map[string]struct{}, metadata map[string]identityMetadata, current *securityState, syncMode, pruneOrphans bool, today string) *syncPlan {
+	plan := &syncPlan{}
+	wanted := make(map[mapping]struct{})
+	for domain, identitySet := range mappings {
+		for identity := range identitySet {
+			m := mapping{domain, identity}
+			expires := current.metadata[identity].Expires
+			if described, ok := metadata[identity]; ok {
+				expires = described.Expires
+			}
+			if expires != "" && expires <= today {
+				plan.skippedMappings = append(plan.skippedMappings, m)
+				continue
+			}
+			wanted[m] = struct{}{}
+		}
+	}
+
+	for identity := range identities {
+		if _, ok := current.identities[identity]; !ok {
+			plan.addIdentities = append(plan.addIdentities, identity)
+		}
+	}
+	for domain := range domains {
+		if _, ok := current.domains[domain]; !ok {
+			plan.addDomains = append(plan.addDomains, domain)
+		}
+	}
+	for m := range wanted {
+		if _, ok := current.mappings[m]; !ok {
+			plan.addMappings = append(plan.addMappings, m)
+		}
+	}
+	changed := make(map[string]identityMetadata)
+	for identity, m := range metadata {
+		if current.metadata[identity] != m {
+			changed[identity] = m
+		}
+	}
+	plan.setMetadata = sortedMetadata(changed)
+
+	if syncMode {
+		// Identities and domains still referenced by a kept mapping are not orphans
+		usedIdentities := make(map[string]struct{})
+		usedDomains := make(map[string]struct{})
+		for m := range current.mappings {
+			if _, ok := wanted[m]; !ok {
+				plan.removeMappings = append(plan.removeMappings, m)
+				continue
+			}
+			usedIdentities[m.identity] = struct{}{}
+			usedDomains[m.domain] = struct{}{}
+		}
+		if pruneOrphans {
+			for identity := range current.identities {
+				_, listed := identities[identity]
+				_, used := usedIdentities[identity]
+				if !listed && !used {
+					plan.removeIdentities = append(plan.removeIdentities, identity)
+				}
+			}
+			for domain := range current.domains {
+				_, listed := domains[domain]
+				_, used := usedDomains[domain]
+				_, catalogued := current.catalogDomains[domain]
+				switch {
+				case listed || used:
+				case catalogued:
+					plan.keptDomains = append(plan.keptDomains, domain)
+				default:
+					plan.removeDomains = append(plan.removeDomains, domain)
+				}
+			}
+		}
+	}
+
+	for _, list := range [][]string{plan.addIdentities, plan.addDomains, plan.removeIdentities, plan.removeDomains, plan.keptDomains} {
+		sort.Strings(list)
+	}
+	sortMappings(plan.addMappings)
+	sortMappings(plan.removeMappings)
+	sortMappings(plan.skippedMappings)
+	return plan
+}
+
+func sortMappings(ms []mapping) {
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].domain != ms[j].domain {
+			return ms[i].domain < ms[j].domain
+		}
+		return ms[i].identity < ms[j].identity
+	})
+}
+
+// print writes one line per change, "+" for inserts and "-" for deletes
+func (p *syncPlan) print(out io.Writer) {
+	for _, v := range p.addIdentities {
+		fmt.Fprintf(out, "+ identity %s\n", v)
+	}
+	for _, v := range p.addDomains {
+		fmt.Fprintf(out, "+ domain %s\n", v)
+	}
+	for _, m := range p.addMappings {
+		fmt.Fprintf(out, "+ mapping %s -> %s\n", m.domain, m.identity)
+	}
+	for _, m := range p.setMetadata {
+		fmt.Fprintf(out, "~ identity %s owner=%q team=%q environment=%q expires=%q\n", m.Identity, m.Owner, m.Team, m.Environment, m.Expires)
+	}
+	for _, m := range p.removeMappings {
+		fmt.Fprintf(out, "- mapping %s -> %s\n", m.domain, m.identity)
+	}
+	for _, v := range p.removeIdentities {
+		fmt.Fprintf(out, "- identity %s\n", v)
+	}
+	for _, v := range p.removeDomains {
+		fmt.Fprintf(out, "- domain %s\n", v)
+	}
+	for _, v := range p.keptDomains {
+		fmt.Fprintf(out, "= domain %s kept, still referenced by the topic catalog\n", v)
+	}
+	p.printSkipped(out)
+	fmt.Fprintf(out, "%d to add, %d to update, %d to remove\n",
+		len(p.addIdentities)+len(p.addDomains)+len(p.addMappings),
+		len(p.setMetadata),
+		len(p.removeMappings)+len(p.removeIdentities)+len(p.removeDomains))
+}
+
+// printSkipped writes one line per mapping left out because its identity has expired
+func (p *syncPlan) printSkipped(out io.Writer) {
+	for _, m := range p.skippedMappings {
+		fmt.Fprintf(out, "= mapping %s -> %s skipped, identity expired\n", m.domain, m.identity)
+	}
+}
+
+// rowCounts is the number of rows a load inserted into, updated in and deleted from one table.
+type rowCounts struct {
+	inserted, updated, deleted int64
+}
+
+// loadResult reports the rows changed per identity table.
+type loadResult struct {
+	identities, domains, mappings rowCounts
+}
+
+// print writes the row counts per table
+func (r loadResult) print(out io.Writer) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tINSERTED\tUPDATED\tDELETED")
+	fmt.Fprintf(tw, "technical_identities\t%d\t%d\t%d\n", r.identities.inserted, r.identities.updated, r.identities.deleted)
+	fmt.Fprintf(tw, "data_domains\t%d\t%d\t%d\n", r.domains.inserted, r.domains.updated, r.domains.deleted)
+	fmt.Fprintf(tw, "data_domain_identities\t%d\t%d\t%d\n", r.mappings.inserted, r.mappings.updated, r.mappings.deleted)
+	tw.Flush()
+}
+
+// apply executes the plan in a single transaction with one set-based statement per table and
+// change; on any error nothing is changed. Rows that already exist are not counted as inserted.
+func (p *syncPlan) apply(db *sql.DB) (loadResult, error) {
+	var res loadResult
+	tx, err := db.Begin()
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+
+	addIDs, addDomains := splitMappings(p.addMappings)
+	removeIDs, removeDomains := splitMappings(p.removeMappings)
+	metaColumns := make([][]string, 6)
+	for _, m := range p.setMetadata {
+		for i, v := range []string{m.Identity, m.Owner, m.Team, m.Environment, m.Description, m.Expires} {
+			metaColumns[i] = append(metaColumns[i], v)
+		}
+	}
+	// count is nil for the history steps, which change a row per mapping already counted
+	steps := []struct {
+		what  string
+		count *int64
+		query string
+		args  []interface{}
+	}{
+		{"inserting identities", &res.identities.inserted,
+			`INSERT INTO technical_identities (identity) SELECT unnest($1::text[])
+			ON CONFLICT (identity) DO NOTHING`, []interface{}{pq.Array(p.addIdentities)}},
+		{"updating identity metadata", &res.identities.updated,
+			`UPDATE technical_identities t SET owner = u.owner, team = u.team, environment = u.environment,
+				description = u.description, expires_at = NULLIF(u.expires, '')::date
+			FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[])
+				AS u(identity, owner, team, environment, description, expires)
+			WHERE t.identity = u.identity AND (t.owner, t.team, t.environment, t.description, t.expires_at)
+				IS DISTINCT FROM (u.owner, u.team, u.environment, u.description, NULLIF(u.expires, '')::date)`,
+			[]interface{}{pq.Array(metaColumns[0]), pq.Array(metaColumns[1]), pq.Array(metaColumns[2]),
+				pq.Array(metaColumns[3]), pq.Array(metaColumns[4]), pq.Array(metaColumns[5])}},
+		{"inserting domains", &res.domains.inserted,
+			`INSERT INTO data_domains (domain_name) SELECT unnest($1::text[])
+			ON CONFLICT (domain_name) DO NOTHING`, []interface{}{pq.Array(p.addDomains)}},
+		{"inserting mappings", &res.mappings.inserted,
+			`INSERT INTO data_domain_identities (identity, domain_name) SELECT * FROM unnest($1::text[], $2::text[])
+			ON CONFLICT (identity, domain_name) DO NOTHING`, []interface{}{pq.Array(addIDs), pq.Array(addDomains)}},
+		{"opening mapping history", nil,
+			`INSERT INTO data_domain_identity_history (identity, domain_name, valid_from)
+			SELECT u.identity, u.domain_name, now() FROM unnest($1::text[], $2::text[]) AS u(identity, domain_name)
+			WHERE NOT EXISTS (SELECT 1 FROM data_domain_identity_history h
+				WHERE h.identity = u.identity AND h.domain_name = u.domain_name AND h.valid_to IS NULL)`,
+			[]interface{}{pq.Array(addIDs), pq.Array(addDomains)}},
+		{"closing mapping history", nil,
+			`UPDATE data_domain_identity_history SET valid_to = now() WHERE valid_to IS NULL AND (identity, domain_name) IN
+			(SELECT * FROM unnest($1::text[], $2::text[]))`, []interface{}{pq.Array(removeIDs), pq.Array(removeDomains)}},
+		// Mappings go first so that the orphaned identities and domains are no longer referenced
+		{"deleting mappings", &res.mappings.deleted,
+			`DELETE FROM data_domain_identities WHERE (identity, domain_name) IN
+			(SELECT * FROM unnest($1::text[], $2::text[]))`, []interface{}{pq.Array(removeIDs), pq.Array(removeDomains)}},
+		{"deleting identities", &res.identities.deleted,
+			`DELETE FROM technical_identities WHERE identity = ANY($1::text[])`, []interface{}{pq.Array(p.removeIdentities)}},
+		// A domain catalogued since the plan was made is skipped rather than failing the whole load
+		{"deleting domains", &res.domains.deleted,
+			`DELETE FROM data_domains d WHERE domain_name = ANY($1::text[])
+			AND NOT EXISTS (SELECT 1 FROM topic_catalog c WHERE c.domain_name = d.domain_name)`, []interface{}{pq.Array(p.removeDomains)}},
+	}
+	for _, step := range steps {
+		r, err := tx.Exec(step.query, step.args...)
+		if err != nil {
+			return loadResult{}, fmt.Errorf("%s: %w", step.what, err)
+		}
+		if step.count == nil {
+			continue
+		}
+		if *step.count, err = r.RowsAffected(); err != nil {
+			return loadResult{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return loadResult{}, err
+	}
+	return res, nil
+}
+
+// splitMappings returns the identity and domain columns of the mappings for unnest
+func splitMappings(ms []mapping) (identities, domains []string) {
+	identities = make([]string, len(ms))
+	domains = make([]string, len(ms))
+	for i, m := range ms {
+		identities[i], domains[i] = m.identity, m.domain
+	}
+	return identities, domains
+}
+
+// flattenMappings turns the domain -> identities sets into sorted mapping rows
+func flattenMappings(mappings map[string]map[string]struct{}) []mapping {
+	var ms []mapping
+	for domain, identitySet := range mappings {
+		for identity := range identitySet {
+			ms = append(ms, mapping{domain, identity})
+		}
+	}
+	sortMappings(ms)
+	return ms
+}
+
+// entry is one line of an identity list: a domain and the identities mapped to it.
+type entry struct {
+	line       int
+	raw        string
+	domain     string
+	identities []string
+}
+
+// rejection is a line left out of the load and the reason why.
+type rejection struct {
+	line   int
+	raw    string
+	reason string
+}
+
+var (
+	validDomain   = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	validIdentity = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+)
+
+// mappingReader parses one input format into entries for the validator and loader. Each
+// entry carries the line (or, for YAML and JSON, the list position) it came from. Formats
+// that can describe identities also return their metadata.
+type mappingReader interface {
+	Read(r io.Reader) ([]entry, []identityMetadata, []rejection)
+}
+
+var mappingReaders = map[string]mappingReader{
+	"csv":  securityListReader{},
+	"yaml": documentReader{unmarshal: yaml.Unmarshal},
+	"json": documentReader{unmarshal: json.Unmarshal},
+	"ldif": ldifReader{},
+}
+
+// readerFor returns the reader for the format, or for the file extension when format is empty
+func readerFor(format, path string) (mappingReader, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			format = "yaml"
+		case ".json":
+			format = "json"
+		case ".ldif":
+			format = "ldif"
+		default:
+			format = "csv"
+		}
+	}
+	r, ok := mappingReaders[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return r, nil
+}
+
+// securityListReader reads the security.list format: one "domain,identity1:identity2" per line.
+type securityListReader struct{}
+
+// Read parses the lines. Lines that are not valid CSV or do not have exactly two fields
+// are rejected; blank lines and comment lines starting with # are skipped.
+func (securityListReader) Read(r io.Reader) ([]entry, []identityMetadata, []rejection) {
+	reader := csv.NewReader(r)
 	reader.Comma = ','
+	reader.Comment = '#'
 	reader.FieldsPerRecord = -1
 
+	var entries []entry
+	var rejects []rejection
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			pe, ok := err.(*csv.ParseError)
+			if !ok {
+				// A read error ends the input
+				rejects = append(rejects, rejection{0, "", err.Error()})
+				break
+			}
+			rejects = append(rejects, rejection{pe.StartLine, strings.Join(record, ","), err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		raw := strings.Join(record, ",")
+		if len(record) != 2 {
+			rejects = append(rejects, rejection{line, raw, fmt.Sprintf("expected 2 fields, got %d", len(record))})
+			continue
+		}
+		entries = append(entries, entry{
+			line:       line,
+			raw:        raw,
+			domain:     strings.TrimSpace(record[0]),
+			identities: strings.Split(strings.TrimSpace(record[1]), ":"),
+		})
+	}
+	return entries, nil, rejects
+}
+
+// validateEntries collects the identities, domains and mappings of the valid entries and
+// rejects entries with an invalid domain or identity, duplicate lines and identities that
+// differ only in case from one seen earlier. A rejected entry contributes nothing.
+func validateEntries(entries []entry) (map[string]struct{}, map[string]struct{}, map[string]map[string]struct{}, []rejection) {
 	identities := make(map[string]struct{})
 	domains := make(map[string]struct{})
 	mappings := make(map[string]map[string]struct{})
+	var rejects []rejection
+
+	seenLines := make(map[string]int)       // normalized line -> first line number
+	seenIdentity := make(map[string]string) // lower-case identity -> spelling seen first
+	identityLine := make(map[string]int)
+	for _, e := range entries {
+		reason := ""
+		key := e.domain + "," + strings.Join(trimAll(e.identities), ":")
+		if first, dup := seenLines[key]; dup {
+			reason = fmt.Sprintf("duplicate of line %d", first)
+		} else if e.domain == "" {
+			reason = "empty domain"
+		} else if !validDomain.MatchString(e.domain) {
+			reason = fmt.Sprintf("domain %q contains illegal characters", e.domain)
+		}
+		for _, identity := range e.identities {
+			if reason != "" {
+				break
+			}
+			identity = strings.TrimSpace(identity)
+			lower := strings.ToLower(identity)
+			switch {
+			case identity == "":
+				reason = "empty identity"
+			case !validIdentity.MatchString(identity):
+				reason = fmt.Sprintf("identity %q contains illegal characters", identity)
+			case seenIdentity[lower] != "" && seenIdentity[lower] != identity:
+				reason = fmt.Sprintf("identity %q conflicts in case with %q on line %d", identity, seenIdentity[lower], identityLine[lower])
+			}
+		}
+		if reason != "" {
+			rejects = append(rejects, rejection{e.line, e.raw, reason})
+			continue
+		}
+
+		seenLines[key] = e.line
+		domains[e.domain] = struct{}{}
+		if _, exists := mappings[e.domain]; !exists {
+			mappings[e.domain] = make(map[string]struct{})
+		}
+		for _, identity := range trimAll(e.identities) {
+			lower := strings.ToLower(identity)
+			if _, ok := seenIdentity[lower]; !ok {
+				seenIdentity[lower], identityLine[lower] = identity, e.line
+			}
+			identities[identity] = struct{}{}
+			mappings[e.domain][identity] = struct{}{}
+		}
+	}
+	return identities, domains, mappings, rejects
+}
+
+func trimAll(values []string) []string {
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return trimmed
+}
 
-	records, err := reader.ReadAll()
+// writeRejects writes the rejected lines as CSV with the line number and reason
+func writeRejects(path string, rejects []rejection) error {
+	f, err := os.Create(path)
 	if err != nil {
-		fmt.Println("Error reading file:", err)
-		return
+		return err
+	}
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"line", "reason", "record"})
+	for _, r := range rejects {
+		cw.Write([]string{strconv.Itoa(r.line), r.reason, r.raw})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
+}
 
-	for _, record := range records {
-		if len(record) != 2 {
+// mappingDocument is the YAML and JSON layout of identity mappings.
+type mappingDocument struct {
+	Domains    []domainIdentities `yaml:"domains" json:"domains"`
+	Identities []identityMetadata `yaml:"identities,omitempty" json:"identities,omitempty"`
+}
+
+type domainIdentities struct {
+	Name       string   `yaml:"name" json:"name"`
+	Identities []string `yaml:"identities" json:"identities"`
+}
+
+// identityMetadata describes who owns a technical identity and until when it may be used.
+type identityMetadata struct {
+	Identity    string `yaml:"identity" json:"identity"`
+	Owner       string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	Team        string `yaml:"team,omitempty" json:"team,omitempty"`
+	Environment string `yaml:"environment,omitempty" json:"environment,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Expires     string `yaml:"expires,omitempty" json:"expires,omitempty"` // YYYY-MM-DD, empty for never
+}
+
+// documentReader reads YAML or JSON documents listing the identities of each domain and,
+// optionally, the metadata of identities:
+//
+//	domains:
+//	  - name: sales
+//	    identities: [app1, app2]
+//	identities:
+//	  - identity: app1
+//	    owner: jane.doe@example.com
+//	    team: payments
+//	    environment: prod
+//	    description: Settlement batch jobs
+//	    expires: 2025-12-31
+type documentReader struct {
+	unmarshal func([]byte, interface{}) error
+}
+
+func (d documentReader) Read(r io.Reader) ([]entry, []identityMetadata, []rejection) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, []rejection{{0, "", err.Error()}}
+	}
+	var doc mappingDocument
+	if err := d.unmarshal(data, &doc); err != nil {
+		return nil, nil, []rejection{{0, "", err.Error()}}
+	}
+	entries := make([]entry, len(doc.Domains))
+	for i, dom := range doc.Domains {
+		entries[i] = entry{
+			line:       i + 1,
+			raw:        dom.Name + "," + strings.Join(dom.Identities, ":"),
+			domain:     strings.TrimSpace(dom.Name),
+			identities: dom.Identities,
+		}
+	}
+	return entries, doc.Identities, nil
+}
+
+// validateMetadata checks the identity metadata of a document and returns it by identity.
+// Rejections carry the position in the identities list.
+func validateMetadata(metadata []identityMetadata) (map[string]identityMetadata, []rejection) {
+	valid := make(map[string]identityMetadata)
+	var rejects []rejection
+	for i, m := range metadata {
+		m = identityMetadata{
+			Identity:    strings.TrimSpace(m.Identity),
+			Owner:       strings.TrimSpace(m.Owner),
+			Team:        strings.TrimSpace(m.Team),
+			Environment: strings.TrimSpace(m.Environment),
+			Description: strings.TrimSpace(m.Description),
+			Expires:     strings.TrimSpace(m.Expires),
+		}
+		reason := ""
+		if _, dup := valid[m.Identity]; dup {
+			reason = fmt.Sprintf("metadata for identity %q given twice", m.Identity)
+		} else if !validIdentity.MatchString(m.Identity) {
+			reason = fmt.Sprintf("identity %q contains illegal characters", m.Identity)
+		} else if _, err := time.Parse("2006-01-02", m.Expires); m.Expires != "" && err != nil {
+			reason = fmt.Sprintf("expiry %q of identity %s is not a YYYY-MM-DD date", m.Expires, m.Identity)
+		}
+		if reason != "" {
+			rejects = append(rejects, rejection{i + 1, "identity " + m.Identity, reason})
 			continue
 		}
-		domain := strings.TrimSpace(record[0])
-		identityList := strings.Split(strings.TrimSpace(record[1]), ":")
+		valid[m.Identity] = m
+	}
+	return valid, rejects
+}
+
+// sortedMetadata returns the metadata ordered by identity
+func sortedMetadata(metadata map[string]identityMetadata) []identityMetadata {
+	list := make([]identityMetadata, 0, len(metadata))
+	for _, m := range metadata {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Identity < list[j].Identity })
+	return list
+}
+
+// loadMetadata calls fn with the metadata of every identity matching the optional condition
+func loadMetadata(db *sql.DB, where string, fn func(identityMetadata)) error {
+	query := `SELECT identity, owner, team, environment, description, COALESCE(to_char(expires_at, 'YYYY-MM-DD'), '')
+		FROM technical_identities`
+	if where != "" {
+		query += " WHERE " + where
+	}
+	rows, err := db.Query(query + " ORDER BY identity")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m identityMetadata
+		if err := rows.Scan(&m.Identity, &m.Owner, &m.Team, &m.Environment, &m.Description, &m.Expires); err != nil {
+			return err
+		}
+		fn(m)
+	}
+	return rows.Err()
+}
+
+// ldifReader reads LDAP group exports: the cn of each group is the domain and the uid of
+// each member (member, uniqueMember or memberUid) is an identity.
+type ldifReader struct{}
 
-		domains[domain] = struct{}{}
+func (ldifReader) Read(r io.Reader) ([]entry, []identityMetadata, []rejection) {
+	var entries []entry
+	var rejects []rejection
 
-		if _, exists := mappings[domain]; !exists {
-			mappings[domain] = make(map[string]struct{})
+	var attrs [][2]string // attribute name and value of the current record
+	start := 0
+	flush := func() {
+		defer func() { attrs = nil }()
+		var e entry
+		var members int
+		for _, a := range attrs {
+			switch strings.ToLower(a[0]) {
+			case "dn":
+				e.raw = "dn: " + a[1]
+			case "cn":
+				if e.domain == "" {
+					e.domain = strings.TrimSpace(a[1])
+				}
+			case "memberuid":
+				members++
+				e.identities = append(e.identities, a[1])
+			case "member", "uniquemember":
+				members++
+				uid := dnUID(a[1])
+				if uid == "" {
+					rejects = append(rejects, rejection{start, e.raw, fmt.Sprintf("member %q has no uid", a[1])})
+					return
+				}
+				e.identities = append(e.identities, uid)
+			}
+		}
+		// Records without members are not groups (or are empty ones) and map nothing
+		if members == 0 {
+			return
 		}
+		e.line = start
+		entries = append(entries, e)
+	}
 
-		for _, identity := range identityList {
-			identity = strings.TrimSpace(identity)
-			identities[identity] = struct{}{}
-			mappings[domain][identity] = struct{}{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, " "):
+			// A leading space continues the previous value
+			if len(attrs) > 0 {
+				attrs[len(attrs)-1][1] += line[1:]
+			}
+		default:
+			if len(attrs) == 0 {
+				start = n
+			}
+			name, value, ok := strings.Cut(line, ":")
+			if !ok {
+				rejects = append(rejects, rejection{n, line, "expected attribute: value"})
+				continue
+			}
+			if strings.HasPrefix(value, ":") {
+				decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+				if err != nil {
+					rejects = append(rejects, rejection{n, line, "invalid base64 value"})
+					continue
+				}
+				value = string(decoded)
+			}
+			attrs = append(attrs, [2]string{name, strings.TrimSpace(value)})
 		}
 	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		rejects = append(rejects, rejection{0, "", err.Error()})
+	}
+	return entries, nil, rejects
+}
 
-	connStr := "user=yourusername dbname=yourdbname sslmode=disable" 
-	db, err := sql.Open("postgres", connStr)
+// dnUID returns the uid attribute of a distinguished name such as uid=app1,ou=people,dc=example
+func dnUID(dn string) string {
+	for _, rdn := range strings.Split(dn, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(rdn), "=")
+		if ok && strings.EqualFold(name, "uid") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// runExport implements the "export" subcommand: it writes data_domain_identities in one of the
+// input formats, domains and identities sorted, so that exports diff cleanly and load back unchanged.
+// YAML and JSON exports also carry the identity metadata.
+func runExport(configFile string, args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "csv", "Output format: csv (security.list), yaml, json or rows (one domain,identity per line)")
+	output := fs.String("o", "", "Output file (default stdout)")
+	fs.Parse(args)
+
+	db, err := openDatabase(configFile)
 	if err != nil {
 		fmt.Println("Error connecting to database:", err)
-		return
+		return 1
 	}
 	defer db.Close()
 
-	for identity := range identities {
-		_, err := db.Exec("INSERT INTO technical_identities (identity) VALUES ($1) ON CONFLICT (identity) DO NOTHING", identity)
+	doc, err := loadMappingDocument(db)
+	if err != nil {
+		fmt.Println("Error reading mappings:", err)
+		return 1
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			fmt.Println("Error creating output file:", err)
+			return 1
+		}
+	}
+	err = writeMappings(out, doc, *format)
+	if *output != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Println("Error writing export:", err)
+		return 1
+	}
+	return 0
+}
+
+// loadMappingDocument reads data_domain_identities ordered by domain and identity, and the
+// metadata of the identities that have any
+func loadMappingDocument(db *sql.DB) (*mappingDocument, error) {
+	rows, err := db.Query("SELECT domain_name, identity FROM data_domain_identities ORDER BY domain_name, identity")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	doc := &mappingDocument{Domains: []domainIdentities{}}
+	for rows.Next() {
+		var domain, identity string
+		if err := rows.Scan(&domain, &identity); err != nil {
+			return nil, err
+		}
+		if n := len(doc.Domains); n == 0 || doc.Domains[n-1].Name != domain {
+			doc.Domains = append(doc.Domains, domainIdentities{Name: domain})
+		}
+		last := &doc.Domains[len(doc.Domains)-1]
+		last.Identities = append(last.Identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	err = loadMetadata(db, "owner <> '' OR team <> '' OR environment <> '' OR description <> '' OR expires_at IS NOT NULL",
+		func(m identityMetadata) { doc.Identities = append(doc.Identities, m) })
+	return doc, err
+}
+
+// writeMappings renders the mappings in the given format
+func writeMappings(out io.Writer, doc *mappingDocument, format string) error {
+	switch format {
+	case "csv":
+		// security.list has no quoting: domain,id1:id2
+		bw := bufio.NewWriter(out)
+		for _, d := range doc.Domains {
+			fmt.Fprintf(bw, "%s,%s\n", d.Name, strings.Join(d.Identities, ":"))
+		}
+		return bw.Flush()
+	case "rows":
+		cw := csv.NewWriter(out)
+		cw.Write([]string{"domain", "identity"})
+		for _, d := range doc.Domains {
+			for _, identity := range d.Identities {
+				cw.Write([]string{d.Name, identity})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case "yaml":
+		data, err := yaml.Marshal(doc)
 		if err != nil {
-			fmt.Println("Error inserting identity:", err)
+			return err
 		}
+		_, err = out.Write(data)
+		return err
+	default:
+		return fmt.Errorf("unknown format %q", format)
 	}
+}
 
-	for domain := range domains {
-		_, err := db.Exec("INSERT INTO data_domains (domain_name) VALUES ($1) ON CONFLICT (domain_name) DO NOTHING", domain)
+// expiredMappings lists the mappings of identities expired on the given date, grouped by owner
+const expiredMappings = `SELECT i.owner, i.team, i.identity, to_char(i.expires_at, 'YYYY-MM-DD'), m.domain_name
+	FROM technical_identities i JOIN data_domain_identities m ON m.identity = i.identity
+	WHERE i.expires_at <= $1
+	ORDER BY i.owner, i.identity, m.domain_name`
+
+// runExpire implements the "expire" subcommand, meant to run from cron: it lists the mappings of
+// expired identities, revokes them with -revoke, and writes a report per owner with -report
+func runExpire(configFile string, args []string) int {
+	fs := flag.NewFlagSet("expire", flag.ExitOnError)
+	revoke := fs.Bool("revoke", false, "Delete the mappings of expired identities")
+	asOf := fs.String("as-of", "", "Treat identities expiring on or before this date as expired (default today)")
+	format := fs.String("format", "table", "Output format: table, csv or json")
+	report := fs.String("report", "", "Write a report per owner to this file")
+	fs.Parse(args)
+
+	day := time.Now()
+	if *asOf != "" {
+		var err error
+		if day, err = time.Parse("2006-01-02", *asOf); err != nil {
+			fmt.Println("Error: invalid -as-of, use YYYY-MM-DD")
+			return 1
+		}
+	}
+
+	db, err := openDatabase(configFile)
+	if err != nil {
+		fmt.Println("Error connecting to database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	rows, err := queryStrings(db, expiredMappings, day.Format("2006-01-02"))
+	if err != nil {
+		fmt.Println("Error reading expired identities:", err)
+		return 1
+	}
+	if err := writeTable(os.Stdout, []string{"owner", "team", "identity", "expires", "domain"}, rows, *format); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+
+	if *revoke && len(rows) > 0 {
+		plan := &syncPlan{}
+		for _, row := range rows {
+			plan.removeMappings = append(plan.removeMappings, mapping{domain: row[4], identity: row[2]})
+		}
+		result, err := plan.apply(db)
 		if err != nil {
-			fmt.Println("Error inserting domain:", err)
+			fmt.Println("Error revoking mappings, nothing was changed:", err)
+			return 1
 		}
+		fmt.Fprintf(os.Stderr, "Revoked %d mappings of expired identities\n", result.mappings.deleted)
 	}
 
-	for domain, identitySet := range mappings {
-		for identity := range identitySet {
-			_, err := db.Exec("INSERT INTO data_domain_identities (identity, domain_name) VALUES ($1, $2) ON CONFLICT (identity, domain_name) DO NOTHING", identity, domain)
-			if err != nil {
-				fmt.Println("Error inserting mapping:", err)
+	if *report != "" {
+		f, err := os.Create(*report)
+		if err != nil {
+			fmt.Println("Error creating report:", err)
+			return 1
+		}
+		writeOwnerReport(f, rows, *revoke)
+		if err := f.Close(); err != nil {
+			fmt.Println("Error writing report:", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeOwnerReport writes one section per owner listing their expired identities and the
+// domains each could access; rows are those of expiredMappings
+func writeOwnerReport(out io.Writer, rows [][]string, revoked bool) {
+	action := "will be revoked by the next run with -revoke"
+	if revoked {
+		action = "has been revoked"
+	}
+	for i, row := range rows {
+		owner, team, identity, expires, domain := row[0], row[1], row[2], row[3], row[4]
+		newOwner := i == 0 || owner != rows[i-1][0]
+		if newOwner {
+			if i > 0 {
+				fmt.Fprintln(out)
 			}
+			if owner == "" {
+				owner = "(no owner recorded)"
+			}
+			fmt.Fprintf(out, "Owner: %s\n", owner)
+			fmt.Fprintf(out, "The following technical identities have expired; their data domain access %s.\n", action)
+		}
+		if newOwner || identity != rows[i-1][2] {
+			if team != "" {
+				team = ", team " + team
+			}
+			fmt.Fprintf(out, "  %s (expired %s%s): %s", identity, expires, team, domain)
+		} else {
+			fmt.Fprintf(out, ", %s", domain)
+		}
+		if i == len(rows)-1 || rows[i+1][0] != row[0] || rows[i+1][2] != identity {
+			fmt.Fprintln(out)
 		}
 	}
+}
 
-	fmt.Println("Data inserted successfully!")
+// accessQueries are the "query" subcommands. Arguments are name patterns in which * matches
+// any run of characters and ? a single character. With -as-of, data_domain_identities is
+// replaced by the mappings valid at that time (see asOfMappings).
+var accessQueries = map[string]struct {
+	argUsage string
+	args     int
+	columns  []string
+	sql      string
+}{
+	"identities": {"DOMAIN", 1, []string{"domain", "identity", "since"},
+		`SELECT domain_name, identity, created_at FROM data_domain_identities
+		WHERE domain_name LIKE $1 ESCAPE '\' ORDER BY domain_name, identity`},
+	"domains": {"IDENTITY", 1, []string{"identity", "domain", "since"},
+		`SELECT identity, domain_name, created_at FROM data_domain_identities
+		WHERE identity LIKE $1 ESCAPE '\' ORDER BY identity, domain_name`},
+	"shared": {"DOMAIN1 DOMAIN2", 2, []string{"identity", "domain1", "domain2"},
+		`SELECT a.identity, a.domain_name, b.domain_name
+		FROM data_domain_identities a
+		JOIN data_domain_identities b ON a.identity = b.identity AND a.domain_name <> b.domain_name
+		WHERE a.domain_name LIKE $1 ESCAPE '\' AND b.domain_name LIKE $2 ESCAPE '\'
+		ORDER BY a.identity, a.domain_name, b.domain_name`},
+	"empty": {"[DOMAIN]", 1, []string{"domain", "created"},
+		`SELECT d.domain_name, d.created_at FROM data_domains d
+		WHERE d.domain_name LIKE $1 ESCAPE '\'
+		AND NOT EXISTS (SELECT 1 FROM data_domain_identities m WHERE m.domain_name = d.domain_name)
+		ORDER BY d.domain_name`},
+	"history": {"DOMAIN IDENTITY", 2, []string{"domain", "identity", "from", "to"},
+		`SELECT domain_name, identity, valid_from, valid_to FROM data_domain_identity_history
+		WHERE domain_name LIKE $1 ESCAPE '\' AND identity LIKE $2 ESCAPE '\'
+		ORDER BY domain_name, identity, valid_from`},
+}
+
+// asOfMappings selects the mappings valid at the time given as parameter $n, with the start
+// of their validity as created_at
+func asOfMappings(n int) string {
+	return fmt.Sprintf(`(SELECT identity, domain_name, valid_from AS created_at FROM data_domain_identity_history
+		WHERE valid_from <= $%[1]d AND (valid_to IS NULL OR valid_to > $%[1]d))`, n)
+}
+
+// parseAsOf accepts a date, taken as midnight UTC, or an RFC 3339 timestamp
+func parseAsOf(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -as-of %q: use YYYY-MM-DD or an RFC 3339 timestamp", v)
+	}
+	return t, nil
+}
+
+// runQuery implements the "query" subcommand for access reviews
+func runQuery(configFile string, args []string) int {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	format := fs.String("format", "table", "Output format: table, csv or json")
+	asOf := fs.String("as-of", "", "Answer from the mapping history as of this date or RFC 3339 timestamp")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage:")
+		for _, name := range []string{"identities", "domains", "shared", "empty"} {
+			fmt.Fprintf(fs.Output(), "  query %s [-format table|csv|json] [-as-of TIME] %s\n", name, accessQueries[name].argUsage)
+		}
+		fmt.Fprintf(fs.Output(), "  query history [-format table|csv|json] %s\n", accessQueries["history"].argUsage)
+		fmt.Fprintln(fs.Output(), "Names may contain the wildcards * and ?.")
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return 1
+	}
+	q, ok := accessQueries[args[0]]
+	fs.Parse(args[1:])
+	params := fs.Args()
+	if args[0] == "empty" && len(params) == 0 {
+		params = []string{"*"}
+	}
+	if !ok || len(params) != q.args || (*asOf != "" && args[0] == "history") {
+		fs.Usage()
+		return 1
+	}
+
+	db, err := openDatabase(configFile)
+	if err != nil {
+		fmt.Println("Error connecting to database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	patterns := make([]interface{}, len(params))
+	for i, p := range params {
+		patterns[i] = likePattern(p)
+	}
+	query := q.sql
+	if *asOf != "" {
+		t, err := parseAsOf(*asOf)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return 1
+		}
+		query = strings.ReplaceAll(query, "data_domain_identities", asOfMappings(len(patterns)+1))
+		patterns = append(patterns, t)
+	}
+	rows, err := queryStrings(db, query, patterns...)
+	if err != nil {
+		fmt.Println("Error running query:", err)
+		return 1
+	}
+	if err := writeTable(os.Stdout, q.columns, rows, *format); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// likePattern turns a * and ? wildcard pattern into a LIKE pattern with \ as escape character
+func likePattern(p string) string {
+	p = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(p)
+	return strings.NewReplacer("*", "%", "?", "_").Replace(p)
+}
+
+// queryStrings runs a query and returns every column as a string; timestamps are formatted as dates
+func queryStrings(db *sql.DB, query string, args ...interface{}) ([][]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := [][]string{}
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(cols))
+		for i, v := range values {
+			switch v := v.(type) {
+			case nil:
+			case time.Time:
+				row[i] = v.Format("2006-01-02")
+			case []byte:
+				row[i] = string(v)
+			default:
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// writeTable renders rows as an aligned table, CSV with a header, or a JSON array of objects
+func writeTable(out io.Writer, columns []string, rows [][]string, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(out)
+		cw.Write(columns)
+		cw.WriteAll(rows)
+		return cw.Error()
+	case "json":
+		objects := make([]map[string]string, len(rows))
+		for i, row := range rows {
+			objects[i] = make(map[string]string, len(columns))
+			for j, col := range columns {
+				objects[i][col] = row[j]
+			}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(objects)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// securityListFixture mixes comments, blank lines, padding and identities that YAML would
+// read as numbers or booleans if they were written unquoted.
+const securityListFixture = `# domain,identities
+sales,app1:app2
+
+# numeric and boolean looking identities
+finance, 123 : true:0x1F
+#disabled,app9
+logistics,app2:svc.batch@corp
+`
+
+var fixtureMappings = []mapping{
+	{"finance", "0x1F"}, {"finance", "123"}, {"finance", "true"},
+	{"logistics", "app2"}, {"logistics", "svc.batch@corp"},
+	{"sales", "app1"}, {"sales", "app2"},
+}
+
+// parseMappings reads input with the reader for format and returns the validated mappings
+// and metadata, failing the test on any rejected line
+func parseMappings(t *testing.T, format, input string) ([]mapping, map[string]identityMetadata) {
+	t.Helper()
+	reader, err := readerFor(format, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, metadata, rejects := reader.Read(strings.NewReader(input))
+	_, _, mappings, invalid := validateEntries(entries)
+	described, badMetadata := validateMetadata(metadata)
+	for _, r := range append(append(rejects, invalid...), badMetadata...) {
+		t.Errorf("%s: rejected line %d %q: %s", format, r.line, r.raw, r.reason)
+	}
+	return flattenMappings(mappings), described
+}
+
+// exportDocument builds the document runExport writes, domains and identities sorted
+func exportDocument(mappings []mapping, metadata map[string]identityMetadata) *mappingDocument {
+	doc := &mappingDocument{Domains: []domainIdentities{}, Identities: sortedMetadata(metadata)}
+	for _, m := range mappings {
+		if n := len(doc.Domains); n == 0 || doc.Domains[n-1].Name != m.domain {
+			doc.Domains = append(doc.Domains, domainIdentities{Name: m.domain})
+		}
+		last := &doc.Domains[len(doc.Domains)-1]
+		last.Identities = append(last.Identities, m.identity)
+	}
+	return doc
+}
+
+func TestSecurityListComments(t *testing.T) {
+	got, _ := parseMappings(t, "csv", securityListFixture)
+	if !reflect.DeepEqual(got, fixtureMappings) {
+		t.Errorf("mappings = %v, want %v", got, fixtureMappings)
+	}
+}
+
+func TestDocumentReadersKeepIdentitiesAsStrings(t *testing.T) {
+	yamlInput := `domains:
+  - name: finance
+    identities: [123, true, 0x1F]
+  - name: logistics
+    identities: [app2, svc.batch@corp]
+  - name: sales
+    identities: [app1, app2]
+identities:
+  - identity: 123
+    owner: jane.doe@example.com
+    expires: 2030-01-31
+`
+	jsonInput := `{"domains": [
+  {"name": "finance", "identities": ["123", "true", "0x1F"]},
+  {"name": "logistics", "identities": ["app2", "svc.batch@corp"]},
+  {"name": "sales", "identities": ["app1", "app2"]}
+], "identities": [{"identity": "123", "owner": "jane.doe@example.com", "expires": "2030-01-31"}]}`
+	want := map[string]identityMetadata{"123": {Identity: "123", Owner: "jane.doe@example.com", Expires: "2030-01-31"}}
+
+	for format, input := range map[string]string{"yaml": yamlInput, "json": jsonInput} {
+		got, metadata := parseMappings(t, format, input)
+		if !reflect.DeepEqual(got, fixtureMappings) {
+			t.Errorf("%s: mappings = %v, want %v", format, got, fixtureMappings)
+		}
+		if !reflect.DeepEqual(metadata, want) {
+			t.Errorf("%s: metadata = %v, want %v", format, metadata, want)
+		}
+	}
+}
+
+// TestExportRoundTrip parses the fixture, exports it in every format that can be loaded
+// again and checks that re-parsing the export yields the same mappings and metadata.
+func TestExportRoundTrip(t *testing.T) {
+	mappings, _ := parseMappings(t, "csv", securityListFixture)
+	metadata := map[string]identityMetadata{
+		"true": {Identity: "true", Owner: "ops", Team: "payments", Environment: "prod", Expires: "2030-01-31"},
+		"0x1F": {Identity: "0x1F", Description: "Hex: looking, with a comma"},
+	}
+	doc := exportDocument(mappings, metadata)
+
+	for _, format := range []string{"csv", "yaml", "json"} {
+		var out bytes.Buffer
+		if err := writeMappings(&out, doc, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		got, gotMetadata := parseMappings(t, format, out.String())
+		if !reflect.DeepEqual(got, mappings) {
+			t.Errorf("%s: round trip mappings = %v, want %v\n%s", format, got, mappings, out.String())
+		}
+		if format == "csv" {
+			continue // security.list carries no metadata
+		}
+		if !reflect.DeepEqual(gotMetadata, metadata) {
+			t.Errorf("%s: round trip metadata = %v, want %v\n%s", format, gotMetadata, metadata, out.String())
+		}
+	}
+}
+
+// TestPlanSyncSkipsExpiredIdentities checks that a load does not restore the mappings the
+// expire command revoked, whether the expiry comes from the file or from the database.
+func TestPlanSyncSkipsExpiredIdentities(t *testing.T) {
+	set := func(keys ...string) map[string]struct{} {
+		s := map[string]struct{}{}
+		for _, k := range keys {
+			s[k] = struct{}{}
+		}
+		return s
+	}
+	identities := set("app1", "app2", "app3", "app4")
+	domains := set("sales")
+	mappings := map[string]map[string]struct{}{"sales": set("app1", "app2", "app3", "app4")}
+	metadata := map[string]identityMetadata{
+		"app1": {Identity: "app1", Expires: "2030-01-31"},
+		"app2": {Identity: "app2", Expires: "2025-06-30"}, // expires today
+		"app4": {Identity: "app4", Expires: "2030-01-31"}, // renewed by the file
+	}
+	current := &securityState{
+		identities: set("app3", "app4"),
+		domains:    set("sales"),
+		mappings:   map[mapping]struct{}{{"sales", "app3"}: {}},
+		metadata: map[string]identityMetadata{
+			"app3": {Identity: "app3", Expires: "2025-01-01"},
+			"app4": {Identity: "app4", Expires: "2025-01-01"},
+		},
+	}
+
+	for _, syncMode := range []bool{false, true} {
+		plan := planSync(identities, domains, mappings, metadata, current, syncMode, false, "2025-06-30")
+		if want := []mapping{{"sales", "app1"}, {"sales", "app4"}}; !reflect.DeepEqual(plan.addMappings, want) {
+			t.Errorf("sync=%v: addMappings = %v, want %v", syncMode, plan.addMappings, want)
+		}
+		if want := []mapping{{"sales", "app2"}, {"sales", "app3"}}; !reflect.DeepEqual(plan.skippedMappings, want) {
+			t.Errorf("sync=%v: skippedMappings = %v, want %v", syncMode, plan.skippedMappings, want)
+		}
+		var removed []mapping
+		if syncMode {
+			removed = []mapping{{"sales", "app3"}}
+		}
+		if !reflect.DeepEqual(plan.removeMappings, removed) {
+			t.Errorf("sync=%v: removeMappings = %v, want %v", syncMode, plan.removeMappings, removed)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ServerConfig holds the HTTP listener settings.
+type ServerConfig struct {
+	Addr              string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration // must cover the slowest exec into the broker pod
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	TLSCertFile       string // serve HTTPS when both TLS files are set
+	TLSKeyFile        string
+	ShutdownTimeout   time.Duration // how long SIGTERM waits for in-flight requests and jobs
+}
+
+var serverConfig = ServerConfig{
+	Addr:              ":8080",
+	ReadHeaderTimeout: 10 * time.Second,
+	ReadTimeout:       30 * time.Second,
+	WriteTimeout:      2 * time.Minute,
+	IdleTimeout:       2 * time.Minute,
+	MaxHeaderBytes:    64 << 10,
+	ShutdownTimeout:   30 * time.Second,
+}
+
+// shutdownHooks run after the listener has drained, sharing what is left of the shutdown deadline.
+var shutdownHooks []func(context.Context)
+
+// registerFlags adds the server settings to the command line.
+func (c *ServerConfig) registerFlags() {
+	flag.StringVar(&c.Addr, "listen", c.Addr, "Address the HTTP server listens on")
+	flag.DurationVar(&c.ReadHeaderTimeout, "read-header-timeout", c.ReadHeaderTimeout, "Maximum time to read request headers")
+	flag.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "Maximum time to read a request including the body")
+	flag.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "Maximum time to write a response")
+	flag.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "Maximum keep-alive idle time")
+	flag.IntVar(&c.MaxHeaderBytes, "max-header-bytes", c.MaxHeaderBytes, "Maximum size of request headers")
+	flag.StringVar(&c.TLSCertFile, "tls-cert", c.TLSCertFile, "TLS certificate file; enables HTTPS together with -tls-key")
+	flag.StringVar(&c.TLSKeyFile, "tls-key", c.TLSKeyFile, "TLS private key file")
+	flag.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "How long to drain in-flight requests and jobs on SIGTERM")
+}
+
+// serve runs the HTTP server until SIGTERM or SIGINT, then stops accepting connections and
+// waits up to ShutdownTimeout for in-flight requests and shutdown hooks to finish.
+func serve(cfg ServerConfig, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+			log.Printf("Starting HTTPS server on %s", cfg.Addr)
+			errc <- srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			log.Printf("Starting server on %s", cfg.Addr)
+			errc <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, draining for up to %s", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	runShutdownHooks(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Print("Shutdown deadline reached with requests still in flight")
+	}
+	return err
+}
+
+// runShutdownHooks runs every shutdown hook in registration order
+func runShutdownHooks(ctx context.Context) {
+	for _, hook := range shutdownHooks {
+		hook(ctx)
+	}
+}
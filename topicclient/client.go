@@ -0,0 +1,470 @@
+// Package topicclient is a typed Go client for the Kafka topic service.
+//
+// The client is hand-maintained, not generated: types and methods follow the operations in
+// openapi.json and method names match the operationIds. When the spec changes, update this
+// package by hand; its tests fail when an operation has no method or a schema's properties
+// differ from the JSON fields of the type of the same name.
+package topicclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the topic service. The zero value is not usable; use New.
+type Client struct {
+	BaseURL    string
+	Token      string // sent as a bearer token when set
+	Cluster    string // sent as ?cluster= when set
+	HTTPClient *http.Client
+}
+
+// New returns a client for the service at baseURL.
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), Token: token, HTTPClient: http.DefaultClient}
+}
+
+// Error is returned for non-2xx responses.
+type Error struct {
+	Status  int    // HTTP status
+	Code    string // e.g. not_found, bad_request
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// TopicSpec describes a topic as created; Catalog is set when the topic was catalogued.
+type TopicSpec struct {
+	Name              string            `json:"name"`
+	Partitions        int               `json:"partitions"`
+	ReplicationFactor int               `json:"replicationFactor"`
+	Configs           map[string]string `json:"configs,omitempty"`
+	Catalog           *CatalogEntry     `json:"catalog,omitempty"`
+}
+
+// CreateTopicRequest is the body of CreateTopic. Zero partitions or replication factor
+// leave the broker default.
+type CreateTopicRequest struct {
+	TopicName         string            `json:"topicName"`
+	Partitions        int               `json:"partitions,omitempty"`
+	ReplicationFactor int               `json:"replicationFactor,omitempty"`
+	Configs           map[string]string `json:"configs,omitempty"`
+	Catalog           *CatalogEntry     `json:"catalog,omitempty"`
+}
+
+// CatalogEntry is the ownership and classification metadata of a topic.
+type CatalogEntry struct {
+	Domain             string     `json:"domain,omitempty"`
+	Owner              string     `json:"owner"`
+	Description        string     `json:"description,omitempty"`
+	Classification     string     `json:"classification,omitempty"`
+	RetentionRationale string     `json:"retentionRationale,omitempty"`
+	Contact            string     `json:"contact"`
+	UpdatedAt          *time.Time `json:"updatedAt,omitempty"`
+}
+
+// CatalogRecord is a catalog entry together with its topic name.
+type CatalogRecord struct {
+	Topic string `json:"topic"`
+	CatalogEntry
+}
+
+// CatalogReport lists topics without a catalog entry or without an owner.
+type CatalogReport struct {
+	Cluster      string   `json:"cluster"`
+	Uncatalogued []string `json:"uncatalogued"`
+	Unowned      []string `json:"unowned"`
+}
+
+// Violation is one naming policy rule a topic name breaks.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// TopicValidation is the result of ValidateTopic.
+type TopicValidation struct {
+	TopicName  string      `json:"topicName"`
+	Valid      bool        `json:"valid"`
+	Violations []Violation `json:"violations"`
+}
+
+// PartitionState is the leader and replica assignment of one partition.
+type PartitionState struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Leader    int    `json:"leader"`
+	Replicas  []int  `json:"replicas"`
+	Isr       []int  `json:"isr"`
+}
+
+// TopicDetail is a topic with the state of each of its partitions.
+type TopicDetail struct {
+	TopicSpec
+	PartitionStates []PartitionState `json:"partitionStates"`
+}
+
+// GroupTopicLag is the lag of a consumer group on one topic.
+type GroupTopicLag struct {
+	Topic      string `json:"topic"`
+	Partitions int    `json:"partitions"`
+	Lag        int64  `json:"lag"`
+}
+
+// ConsumerGroup is a consumer group with its total and per-topic lag.
+type ConsumerGroup struct {
+	Group    string          `json:"group"`
+	TotalLag int64           `json:"totalLag"`
+	Members  int             `json:"members"`
+	Topics   []GroupTopicLag `json:"topics"`
+}
+
+// ACL is one Kafka access control entry. Host, Permission and PatternType default to
+// "*", ALLOW and LITERAL on the server when empty.
+type ACL struct {
+	Principal    string `json:"principal"`
+	Host         string `json:"host,omitempty"`
+	Operation    string `json:"operation"`
+	Permission   string `json:"permission,omitempty"`
+	ResourceType string `json:"resourceType"`
+	ResourceName string `json:"resourceName"`
+	PatternType  string `json:"patternType,omitempty"`
+}
+
+// ApplyResult reports the generated ACLs that were added and how many already existed.
+type ApplyResult struct {
+	Added    []ACL `json:"added"`
+	Existing int   `json:"existing"`
+}
+
+// DriftReport compares the ACLs generated from the identity database with the cluster.
+type DriftReport struct {
+	Cluster           string   `json:"cluster"`
+	Missing           []ACL    `json:"missing"`
+	Unexpected        []ACL    `json:"unexpected"`
+	UnknownPrincipals []string `json:"unknownPrincipals"`
+}
+
+// KafkaUser is a principal with SCRAM credentials or quotas.
+type KafkaUser struct {
+	Name       string             `json:"name"`
+	Mechanisms []string           `json:"mechanisms,omitempty"`
+	Quotas     map[string]float64 `json:"quotas,omitempty"`
+}
+
+// Credential is a newly issued SCRAM credential. The password is only returned once.
+type Credential struct {
+	Name      string `json:"name"`
+	Mechanism string `json:"mechanism"`
+	Password  string `json:"password"`
+}
+
+// Quotas are client quotas; nil fields are left unchanged.
+type Quotas struct {
+	ProducerByteRate  *float64 `json:"producerByteRate,omitempty"`
+	ConsumerByteRate  *float64 `json:"consumerByteRate,omitempty"`
+	RequestPercentage *float64 `json:"requestPercentage,omitempty"`
+}
+
+// ProblemPartition is a partition with the health problems found on it.
+type ProblemPartition struct {
+	PartitionState
+	Problems []string `json:"problems"`
+}
+
+// PartitionHealth is the partition health report of a cluster, keyed by topic.
+type PartitionHealth struct {
+	Cluster string                        `json:"cluster"`
+	Status  string                        `json:"status"`
+	Topics  map[string][]ProblemPartition `json:"topics"`
+}
+
+// ConnectorRequest is the body of SaveConnector and UpdateConnector.
+type ConnectorRequest struct {
+	Name   string            `json:"name,omitempty"`
+	Config map[string]string `json:"config"`
+}
+
+// Connector is a Kafka Connect connector. Credential values in Config are redacted as
+// "********"; sending them back unchanged keeps the stored value.
+type Connector struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type,omitempty"`
+	Config map[string]string `json:"config,omitempty"`
+	Status json.RawMessage   `json:"status,omitempty"`
+}
+
+// ListClusters lists the configured cluster names.
+func (c *Client) ListClusters(ctx context.Context) ([]string, error) {
+	var out []string
+	return out, c.do(ctx, "GET", "/clusters", nil, nil, &out)
+}
+
+// ListTopics lists the topic names of the cluster.
+func (c *Client) ListTopics(ctx context.Context) ([]string, error) {
+	var out []string
+	return out, c.do(ctx, "GET", "/topics", nil, nil, &out)
+}
+
+// CreateTopic creates a topic; the name must pass the naming policy.
+func (c *Client) CreateTopic(ctx context.Context, req CreateTopicRequest) (*TopicSpec, error) {
+	out := &TopicSpec{}
+	return out, c.do(ctx, "POST", "/topics", nil, req, out)
+}
+
+// ValidateTopic checks a topic name against the naming policy without creating it.
+func (c *Client) ValidateTopic(ctx context.Context, name string) (*TopicValidation, error) {
+	out := &TopicValidation{}
+	return out, c.do(ctx, "POST", "/topics/validate", nil, map[string]string{"topicName": name}, out)
+}
+
+// DescribeTopic returns a topic with its configs and partitions.
+func (c *Client) DescribeTopic(ctx context.Context, name string) (*TopicDetail, error) {
+	out := &TopicDetail{}
+	return out, c.do(ctx, "GET", "/topics/"+url.PathEscape(name), nil, nil, out)
+}
+
+// DeleteTopic deletes a topic.
+func (c *Client) DeleteTopic(ctx context.Context, name string) error {
+	return c.do(ctx, "DELETE", "/topics/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// ListCatalog lists every catalog entry.
+func (c *Client) ListCatalog(ctx context.Context) ([]CatalogRecord, error) {
+	var out []CatalogRecord
+	return out, c.do(ctx, "GET", "/catalog/topics", nil, nil, &out)
+}
+
+// GetCatalogEntry returns the catalog entry of a topic.
+func (c *Client) GetCatalogEntry(ctx context.Context, topic string) (*CatalogRecord, error) {
+	out := &CatalogRecord{}
+	return out, c.do(ctx, "GET", "/catalog/topics/"+url.PathEscape(topic), nil, nil, out)
+}
+
+// SaveCatalogEntry creates or replaces the catalog entry of a topic.
+func (c *Client) SaveCatalogEntry(ctx context.Context, topic string, entry CatalogEntry) (*CatalogRecord, error) {
+	out := &CatalogRecord{}
+	return out, c.do(ctx, "PUT", "/catalog/topics/"+url.PathEscape(topic), nil, entry, out)
+}
+
+// DeleteCatalogEntry removes the catalog entry of a topic.
+func (c *Client) DeleteCatalogEntry(ctx context.Context, topic string) error {
+	return c.do(ctx, "DELETE", "/catalog/topics/"+url.PathEscape(topic), nil, nil, nil)
+}
+
+// GetCatalogReport lists uncatalogued and unowned topics.
+func (c *Client) GetCatalogReport(ctx context.Context) (*CatalogReport, error) {
+	out := &CatalogReport{}
+	return out, c.do(ctx, "GET", "/catalog/uncatalogued", nil, nil, out)
+}
+
+// ListGroups lists consumer groups; topic may be empty.
+func (c *Client) ListGroups(ctx context.Context, topic string) ([]ConsumerGroup, error) {
+	var out []ConsumerGroup
+	return out, c.do(ctx, "GET", "/groups", optional("topic", topic), nil, &out)
+}
+
+// ListACLs lists ACLs; principal may be empty.
+func (c *Client) ListACLs(ctx context.Context, principal string) ([]ACL, error) {
+	var out []ACL
+	return out, c.do(ctx, "GET", "/acls", optional("principal", principal), nil, &out)
+}
+
+// AddACLs adds ACLs and returns them as applied.
+func (c *Client) AddACLs(ctx context.Context, acls []ACL) ([]ACL, error) {
+	var out []ACL
+	return out, c.do(ctx, "POST", "/acls", nil, acls, &out)
+}
+
+// RemoveACLs removes ACLs and returns them as removed.
+func (c *Client) RemoveACLs(ctx context.Context, acls []ACL) ([]ACL, error) {
+	var out []ACL
+	return out, c.do(ctx, "DELETE", "/acls", nil, acls, &out)
+}
+
+// ListGeneratedACLs returns the ACLs generated from the identity database.
+func (c *Client) ListGeneratedACLs(ctx context.Context) ([]ACL, error) {
+	var out []ACL
+	return out, c.do(ctx, "GET", "/acls/generated", nil, nil, &out)
+}
+
+// ApplyGeneratedACLs adds the generated ACLs missing from the cluster; with dryRun
+// nothing is changed.
+func (c *Client) ApplyGeneratedACLs(ctx context.Context, dryRun bool) (*ApplyResult, error) {
+	out := &ApplyResult{}
+	return out, c.do(ctx, "POST", "/acls/generated/apply", url.Values{"dryRun": {strconv.FormatBool(dryRun)}}, nil, out)
+}
+
+// GetACLDrift compares the generated ACLs with the ACLs of the cluster.
+func (c *Client) GetACLDrift(ctx context.Context) (*DriftReport, error) {
+	out := &DriftReport{}
+	return out, c.do(ctx, "GET", "/acls/drift", nil, nil, out)
+}
+
+// ListUsers lists users with SCRAM credentials or quotas.
+func (c *Client) ListUsers(ctx context.Context) ([]KafkaUser, error) {
+	var out []KafkaUser
+	return out, c.do(ctx, "GET", "/users", nil, nil, &out)
+}
+
+// RotateCredentials creates or rotates the SCRAM-SHA-512 credential of an identity.
+func (c *Client) RotateCredentials(ctx context.Context, identity string) (*Credential, error) {
+	out := &Credential{}
+	return out, c.do(ctx, "POST", "/users/"+url.PathEscape(identity)+"/credentials", nil, nil, out)
+}
+
+// DeleteCredentials deletes the SCRAM-SHA-512 credential of an identity.
+func (c *Client) DeleteCredentials(ctx context.Context, identity string) error {
+	return c.do(ctx, "DELETE", "/users/"+url.PathEscape(identity)+"/credentials", nil, nil, nil)
+}
+
+// SetQuotas sets quotas for entityType "users" or "clients".
+func (c *Client) SetQuotas(ctx context.Context, entityType, name string, quotas Quotas) (*Quotas, error) {
+	out := &Quotas{}
+	return out, c.do(ctx, "PUT", "/quotas/"+entityType+"/"+url.PathEscape(name), nil, quotas, out)
+}
+
+// RemoveQuotas removes the named quotas (e.g. "producerByteRate"), or all of them when none are given.
+func (c *Client) RemoveQuotas(ctx context.Context, entityType, name string, quotas ...string) error {
+	return c.do(ctx, "DELETE", "/quotas/"+entityType+"/"+url.PathEscape(name), url.Values{"quota": quotas}, nil, nil)
+}
+
+// GetPartitionHealth returns the partition health report. A degraded or critical
+// cluster is not an error; check PartitionHealth.Status.
+func (c *Client) GetPartitionHealth(ctx context.Context) (*PartitionHealth, error) {
+	out := &PartitionHealth{}
+	err := c.do(ctx, "GET", "/health/partitions", nil, nil, out)
+	if e, ok := err.(*Error); ok && e.Status == http.StatusServiceUnavailable && out.Status != "" {
+		return out, nil
+	}
+	return out, err
+}
+
+// ListConnectors lists the connectors of a cluster with their status.
+func (c *Client) ListConnectors(ctx context.Context, cluster string) ([]Connector, error) {
+	var out []Connector
+	return out, c.do(ctx, "GET", connectPath(cluster), nil, nil, &out)
+}
+
+// SaveConnector creates or updates the connector named in req.
+func (c *Client) SaveConnector(ctx context.Context, cluster string, req ConnectorRequest) (*Connector, error) {
+	out := &Connector{}
+	return out, c.do(ctx, "POST", connectPath(cluster), nil, req, out)
+}
+
+// UpdateConnector creates or updates the named connector.
+func (c *Client) UpdateConnector(ctx context.Context, cluster, name string, req ConnectorRequest) (*Connector, error) {
+	out := &Connector{}
+	return out, c.do(ctx, "PUT", connectPath(cluster, name), nil, req, out)
+}
+
+// GetConnector returns a connector with its status.
+func (c *Client) GetConnector(ctx context.Context, cluster, name string) (*Connector, error) {
+	out := &Connector{}
+	return out, c.do(ctx, "GET", connectPath(cluster, name), nil, nil, out)
+}
+
+// DeleteConnector deletes a connector.
+func (c *Client) DeleteConnector(ctx context.Context, cluster, name string) error {
+	return c.do(ctx, "DELETE", connectPath(cluster, name), nil, nil, nil)
+}
+
+// ConnectorAction runs "pause", "resume" or "restart" on a connector.
+func (c *Client) ConnectorAction(ctx context.Context, cluster, name, action string) error {
+	return c.do(ctx, "POST", connectPath(cluster, name, action), nil, nil, nil)
+}
+
+// RestartTask restarts one task of a connector.
+func (c *Client) RestartTask(ctx context.Context, cluster, name string, task int) error {
+	return c.do(ctx, "POST", connectPath(cluster, name, "tasks", strconv.Itoa(task), "restart"), nil, nil, nil)
+}
+
+func connectPath(cluster string, parts ...string) string {
+	p := "/connect/" + url.PathEscape(cluster) + "/connectors"
+	for _, part := range parts {
+		p += "/" + url.PathEscape(part)
+	}
+	return p
+}
+
+func optional(key, value string) url.Values {
+	if value == "" {
+		return nil
+	}
+	return url.Values{key: {value}}
+}
+
+// do sends a request and decodes the JSON response into out. Error bodies are
+// decoded into *Error; out is still filled when the error body matches its type.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	if c.Cluster != "" && !strings.HasPrefix(path, "/connect/") {
+		query.Set("cluster", c.Cluster)
+	}
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		apiErr := &Error{Status: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		if json.Unmarshal(data, &e) == nil && e.Error.Code != "" {
+			apiErr.Code, apiErr.Message = e.Error.Code, e.Error.Message
+		} else if out != nil {
+			json.Unmarshal(data, out)
+		}
+		return apiErr
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
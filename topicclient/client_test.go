@@ -0,0 +1,173 @@
+package topicclient
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// spec is the part of openapi.json the conformance tests compare against.
+type spec struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type schema struct {
+	Ref        string                     `json:"$ref"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	AllOf      []schema                   `json:"allOf"`
+}
+
+func loadSpec(t *testing.T) *spec {
+	t.Helper()
+	data, err := os.ReadFile("../openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &spec{}
+	if err := json.Unmarshal(data, s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// properties returns the property names of a schema, following allOf and $ref
+func (s *spec) properties(sc schema) []string {
+	var names []string
+	if sc.Ref != "" {
+		return s.properties(s.Components.Schemas[strings.TrimPrefix(sc.Ref, "#/components/schemas/")])
+	}
+	for name := range sc.Properties {
+		names = append(names, name)
+	}
+	for _, part := range sc.AllOf {
+		names = append(names, s.properties(part)...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseClient returns the exported methods of *Client and the JSON field names of every
+// exported struct type declared in client.go, embedded structs included
+func parseClient(t *testing.T) (map[string]bool, map[string][]string) {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "client.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := map[string]bool{}
+	structs := map[string]*ast.StructType{}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && d.Name.IsExported() {
+				methods[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.IsExported() {
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						structs[ts.Name.Name] = st
+					}
+				}
+			}
+		}
+	}
+
+	var fields func(st *ast.StructType) []string
+	fields = func(st *ast.StructType) []string {
+		var names []string
+		for _, f := range st.Fields.List {
+			if len(f.Names) == 0 {
+				if id, ok := f.Type.(*ast.Ident); ok && structs[id.Name] != nil {
+					names = append(names, fields(structs[id.Name])...)
+				}
+				continue
+			}
+			if f.Tag == nil {
+				continue
+			}
+			tag := reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Get("json")
+			if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+	types := map[string][]string{}
+	for name, st := range structs {
+		types[name] = fields(st)
+	}
+	return methods, types
+}
+
+// TestClientCoversOperations checks that every authenticated operation in openapi.json has
+// a Client method named after its operationId, and that every method maps to an operation.
+func TestClientCoversOperations(t *testing.T) {
+	s := loadSpec(t)
+	methods, _ := parseClient(t)
+
+	operations := map[string]bool{}
+	for path, item := range s.Paths {
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			var op struct {
+				OperationID string             `json:"operationId"`
+				Security    *[]json.RawMessage `json:"security"`
+			}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+			if op.Security != nil && len(*op.Security) == 0 {
+				continue // public: the spec itself, metrics and the UI
+			}
+			name := strings.ToUpper(op.OperationID[:1]) + op.OperationID[1:]
+			operations[name] = true
+			if !methods[name] {
+				t.Errorf("no Client method %s for %s %s", name, strings.ToUpper(method), path)
+			}
+		}
+	}
+	for name := range methods {
+		if name != "Error" && !operations[name] {
+			t.Errorf("Client method %s has no operation in openapi.json", name)
+		}
+	}
+}
+
+// TestTypesMatchSchemas checks that every schema in openapi.json has a struct type of the
+// same name whose JSON fields are exactly the schema's properties.
+func TestTypesMatchSchemas(t *testing.T) {
+	s := loadSpec(t)
+	_, types := parseClient(t)
+
+	for name, sc := range s.Components.Schemas {
+		if name == "Error" {
+			continue // decoded into the Error type by do
+		}
+		fields, ok := types[name]
+		if !ok {
+			t.Errorf("no type for schema %s", name)
+			continue
+		}
+		if want := s.properties(sc); !reflect.DeepEqual(fields, want) {
+			t.Errorf("%s has JSON fields %v, schema has %v", name, fields, want)
+		}
+	}
+	for name := range types {
+		if _, ok := s.Components.Schemas[name]; !ok && name != "Client" && name != "Error" {
+			t.Errorf("type %s has no schema in openapi.json", name)
+		}
+	}
+}
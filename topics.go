@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TopicDetail is a topic together with the state of its partitions.
+type TopicDetail struct {
+	TopicSpec
+	PartitionStates []PartitionState `json:"partitionStates"`
+}
+
+// TopicSpec is the recreatable shape of a topic: partitions, replication and non-default configs.
+type TopicSpec struct {
+	Name              string            `json:"name" yaml:"name"`
+	Partitions        int               `json:"partitions" yaml:"partitions"`
+	ReplicationFactor int               `json:"replicationFactor" yaml:"replicationFactor"`
+	Configs           map[string]string `json:"configs,omitempty" yaml:"configs,omitempty"`
+	Catalog           *CatalogEntry     `json:"catalog,omitempty" yaml:"catalog,omitempty"` // recorded in topic_catalog on create
+}
+
+// topicPattern quotes a topic name for the --topic option of kafka-topics.sh --describe and
+// --delete, which takes a regular expression: "orders.v1" alone would also match "orders-v1"
+func topicPattern(name string) string {
+	return "'" + regexp.QuoteMeta(name) + "'"
+}
+
+// describeTopicInPod describes a single topic, returning nil if it does not exist
+func describeTopicInPod(c *Cluster, name string) (*TopicDetail, error) {
+	if !resourceName.MatchString(name) {
+		return nil, fmt.Errorf("invalid topic name %q", name)
+	}
+	output, err := execInPod(c, fmt.Sprintf("kafka-topics.sh --describe --topic %s %s 2>&1 || true", topicPattern(name), bootstrapArg))
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(output, "UnknownTopicOrPartitionException") || strings.Contains(output, "does not exist") {
+		return nil, nil
+	}
+
+	detail := &TopicDetail{PartitionStates: []PartitionState{}}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := describeFields(scanner.Text())
+		if fields["Topic"] != name {
+			continue
+		}
+		if _, isPartition := fields["Partition"]; isPartition {
+			detail.PartitionStates = append(detail.PartitionStates, parsePartitionState(fields))
+			continue
+		}
+		detail.Name = name
+		detail.Configs = parseConfigList(fields["Configs"])
+		detail.Partitions, _ = strconv.Atoi(fields["PartitionCount"])
+		detail.ReplicationFactor, _ = strconv.Atoi(fields["ReplicationFactor"])
+	}
+	if detail.Name == "" {
+		return nil, fmt.Errorf("unexpected describe output: %s", strings.TrimSpace(output))
+	}
+	return detail, nil
+}
+
+// describeTopics runs kafka-topics.sh --describe for all topics and parses the topic summary lines
+func describeTopics(c *Cluster) ([]TopicSpec, error) {
+	output, err := execInPod(c, "kafka-topics.sh --describe --exclude-internal "+bootstrapArg)
+	if err != nil {
+		return nil, err
+	}
+
+	var topics []TopicSpec
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Partition lines are indented and carry a Partition field; the summary line does not
+		fields := describeFields(line)
+		if _, isPartition := fields["Partition"]; isPartition || fields["Topic"] == "" {
+			continue
+		}
+		spec := TopicSpec{Name: fields["Topic"], Configs: parseConfigList(fields["Configs"])}
+		spec.Partitions, _ = strconv.Atoi(fields["PartitionCount"])
+		spec.ReplicationFactor, _ = strconv.Atoi(fields["ReplicationFactor"])
+		topics = append(topics, spec)
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+	return topics, nil
+}
+
+// describeFields splits a tab-separated "Key: value" line from kafka-topics.sh --describe
+func describeFields(line string) map[string]string {
+	fields := map[string]string{}
+	for _, part := range strings.Split(line, "\t") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			continue
+		}
+		fields[key] = strings.TrimSpace(value)
+	}
+	return fields
+}
+
+// parseConfigList parses "a=1,b=2" config lists; commas inside a value are kept with that value
+func parseConfigList(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	configs := map[string]string{}
+	var last string
+	for _, kv := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok && last != "" {
+			configs[last] += "," + kv
+			continue
+		}
+		configs[key] = value
+		last = key
+	}
+	return configs
+}
+
+// PartitionState is one partition line of kafka-topics.sh --describe.
+type PartitionState struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Leader    int    `json:"leader"` // -1 when the partition has no leader
+	Replicas  []int  `json:"replicas"`
+	Isr       []int  `json:"isr"`
+}
+
+// describePartitions runs kafka-topics.sh --describe with an optional filter such as
+// --under-replicated-partitions and parses the partition lines
+func describePartitions(c *Cluster, filter string) ([]PartitionState, error) {
+	output, err := execInPod(c, "kafka-topics.sh --describe "+filter+" "+bootstrapArg)
+	if err != nil {
+		return nil, err
+	}
+
+	var partitions []PartitionState
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := describeFields(scanner.Text())
+		if _, ok := fields["Partition"]; !ok {
+			continue
+		}
+		partitions = append(partitions, parsePartitionState(fields))
+	}
+	return partitions, nil
+}
+
+// parsePartitionState builds a PartitionState from the fields of a partition line
+func parsePartitionState(fields map[string]string) PartitionState {
+	p := PartitionState{Topic: fields["Topic"], Replicas: parseBrokerList(fields["Replicas"]), Isr: parseBrokerList(fields["Isr"])}
+	p.Partition, _ = strconv.Atoi(fields["Partition"])
+	leader, err := strconv.Atoi(fields["Leader"])
+	if err != nil {
+		leader = -1 // "none"
+	}
+	p.Leader = leader
+	return p
+}
+
+// parseBrokerList parses a comma-separated list of broker ids such as "1,2,3"
+func parseBrokerList(s string) []int {
+	ids := []int{}
+	for _, part := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
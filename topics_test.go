@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+// TestTopicCommandsMatchOneTopic checks that describe and delete pass the name as an escaped,
+// single-quoted pattern, so a dot in the name cannot match other topics.
+func TestTopicCommandsMatchOneTopic(t *testing.T) {
+	calls := recordPodExec(t, "Topic: orders.v1\tTopicId: x\tPartitionCount: 3\tReplicationFactor: 2\tConfigs: retention.ms=1000\n")
+
+	topic, err := describeTopicInPod(testCluster, "orders.v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic.Name != "orders.v1" || topic.Partitions != 3 {
+		t.Errorf("described %+v", topic)
+	}
+	if err := deleteTopicInPod(testCluster, "orders.v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`kafka-topics.sh --describe --topic 'orders\.v1' ` + bootstrapArg + " 2>&1 || true",
+		`kafka-topics.sh --delete --topic 'orders\.v1' ` + bootstrapArg,
+	}
+	if len(*calls) != len(want) {
+		t.Fatalf("%d exec calls, want %d", len(*calls), len(want))
+	}
+	for i, call := range *calls {
+		if call.command != want[i] {
+			t.Errorf("command %d = %q, want %q", i, call.command, want[i])
+		}
+	}
+
+	if err := deleteTopicInPod(testCluster, "orders'; rm -rf /"); err == nil {
+		t.Error("invalid topic name accepted")
+	}
+	if len(*calls) != len(want) {
+		t.Error("invalid topic name reached the pod")
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFiles is the browser UI. It is plain HTML and JavaScript that calls the REST API with
+// the bearer token the user enters, so the files themselves are served without auth.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// uiHandler serves the embedded UI under /ui/
+func uiHandler() http.Handler {
+	root, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/ui/", http.FileServer(http.FS(root)))
+}
+
+// handleRoot sends browsers to the UI and answers every other unknown path with a JSON 404
+func handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	http.Redirect(w, r, "/ui/", http.StatusFound)
+}
@@ -0,0 +1,267 @@
+// Browser UI for the topic service. Every view is rendered from the REST API described
+// in /openapi.json; the bearer token is kept in localStorage when the API requires one.
+"use strict";
+
+const $ = (sel) => document.querySelector(sel);
+
+const state = {
+  token: localStorage.getItem("topicServiceToken") || "",
+  cluster: localStorage.getItem("topicServiceCluster") || "default",
+};
+
+// api calls the service and returns the decoded JSON body, throwing the server's error message
+async function api(method, path, body, query = {}) {
+  const params = new URLSearchParams({ cluster: state.cluster, ...query });
+  const headers = { Accept: "application/json" };
+  if (state.token) headers.Authorization = "Bearer " + state.token;
+  if (body !== undefined) headers["Content-Type"] = "application/json";
+
+  const resp = await fetch(path + "?" + params, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  if (resp.status === 401) {
+    showLogin();
+    throw new Error("Sign in with an API token");
+  }
+  const text = await resp.text();
+  const data = text ? JSON.parse(text) : null;
+  if (!resp.ok) {
+    throw new Error(data && data.error ? data.error.message : resp.status + " " + resp.statusText);
+  }
+  return data;
+}
+
+// el builds a DOM element; strings become text nodes so nothing from the API is parsed as HTML
+function el(tag, attrs = {}, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs)) {
+    if (k.startsWith("on")) node.addEventListener(k.slice(2), v);
+    else node.setAttribute(k, v);
+  }
+  for (const c of children.flat()) {
+    if (c !== null && c !== undefined) node.append(c instanceof Node ? c : String(c));
+  }
+  return node;
+}
+
+// table renders rows as a table; each column is [heading, row => cell, optional class]
+function table(columns, rows) {
+  return el("table", {},
+    el("thead", {}, el("tr", {}, columns.map(([h]) => el("th", {}, h)))),
+    el("tbody", {}, rows.map((r) => el("tr", {}, columns.map(([, f, cls]) => el("td", cls ? { class: cls } : {}, f(r)))))));
+}
+
+// filtered renders a text box that narrows the rows of a table as the user types
+function filtered(placeholder, rows, match, render) {
+  const box = el("input", { class: "filter", type: "search", placeholder });
+  const holder = el("div", {}, render(rows));
+  box.addEventListener("input", () => {
+    const q = box.value.toLowerCase();
+    holder.replaceChildren(render(rows.filter((r) => match(r).toLowerCase().includes(q))));
+  });
+  return [box, holder];
+}
+
+function showError(err) {
+  const p = $("#error");
+  p.textContent = err ? err.message : "";
+  p.hidden = !err;
+}
+
+function showLogin() {
+  $("#login").hidden = false;
+  $("#logout").hidden = true;
+}
+
+const views = {
+  async topics() {
+    const topics = (await api("GET", "/topics")).filter((t) => t).sort();
+    return [
+      el("h2", {}, `Topics (${topics.length})`),
+      filtered("Filter topics", topics, (t) => t, (rows) =>
+        table([["Name", (t) => el("a", { href: "#/topics/" + encodeURIComponent(t) }, t)]], rows)),
+    ];
+  },
+
+  async topic(name) {
+    const [topic, groups] = await Promise.all([
+      api("GET", "/topics/" + encodeURIComponent(name)),
+      api("GET", "/groups", undefined, { topic: name }),
+    ]);
+    const configs = Object.entries(topic.configs || {}).sort(([a], [b]) => a.localeCompare(b));
+    const catalog = topic.catalog || {};
+    return [
+      el("h2", {}, topic.name),
+      el("p", {}, `${topic.partitions} partitions, replication factor ${topic.replicationFactor}`),
+      el("h3", {}, "Catalog"),
+      topic.catalog
+        ? table([["Field", (r) => r[0]], ["Value", (r) => r[1]]], [
+          ["Owner", catalog.owner], ["Contact", catalog.contact], ["Domain", catalog.domain],
+          ["Classification", catalog.classification], ["Description", catalog.description],
+          ["Retention rationale", catalog.retentionRationale],
+        ])
+        : el("p", {}, "No catalog entry."),
+      el("h3", {}, "Configs"),
+      configs.length
+        ? table([["Key", (c) => c[0]], ["Value", (c) => c[1]]], configs)
+        : el("p", {}, "Broker defaults."),
+      el("h3", {}, "Consumer lag"),
+      groups.length
+        ? table([
+          ["Group", (g) => g.group],
+          ["Members", (g) => g.members, "num"],
+          ["Lag", (g) => (g.topics.find((t) => t.topic === name) || { lag: 0 }).lag, "num"],
+        ], groups)
+        : el("p", {}, "No consumer groups."),
+      el("h3", {}, "Partitions"),
+      table([
+        ["Partition", (p) => p.partition, "num"],
+        ["Leader", (p) => p.leader, "num"],
+        ["Replicas", (p) => p.replicas.join(", ")],
+        ["In sync", (p) => p.isr.join(", ")],
+      ], topic.partitionStates),
+    ];
+  },
+
+  async groups() {
+    const groups = await api("GET", "/groups");
+    return [
+      el("h2", {}, `Consumer groups (${groups.length})`),
+      filtered("Filter groups or topics", groups, (g) => g.group + " " + g.topics.map((t) => t.topic).join(" "), (rows) =>
+        table([
+          ["Group", (g) => g.group],
+          ["Members", (g) => g.members, "num"],
+          ["Topics", (g) => g.topics.map((t) => `${t.topic} (${t.lag})`).join(", ")],
+          ["Total lag", (g) => g.totalLag, "num"],
+        ], rows)),
+    ];
+  },
+
+  async acls() {
+    const acls = await api("GET", "/acls");
+    const resource = (a) => `${a.resourceType}:${a.resourceName}${a.patternType === "PREFIXED" ? "*" : ""}`;
+    return [
+      el("h2", {}, `ACLs (${acls.length})`),
+      filtered("Filter principals or resources", acls, (a) => a.principal + " " + resource(a), (rows) =>
+        table([
+          ["Principal", (a) => a.principal],
+          ["Operation", (a) => a.operation],
+          ["Permission", (a) => a.permission],
+          ["Resource", resource],
+          ["Host", (a) => a.host],
+        ], rows)),
+    ];
+  },
+
+  async create() {
+    const form = $("#create-form").content.firstElementChild.cloneNode(true);
+    const name = form.elements.topicName;
+    const violations = form.querySelector("#violations");
+
+    // Check the name against the naming policy while the user types
+    let timer;
+    name.addEventListener("input", () => {
+      clearTimeout(timer);
+      timer = setTimeout(async () => {
+        if (!name.value) return violations.replaceChildren();
+        try {
+          const res = await api("POST", "/topics/validate", { topicName: name.value });
+          violations.replaceChildren(...(res.valid
+            ? [el("li", { class: "ok" }, "Name is valid")]
+            : res.violations.map((v) => el("li", {}, v.message))));
+        } catch (err) {
+          showError(err);
+        }
+      }, 300);
+    });
+
+    form.addEventListener("submit", async (e) => {
+      e.preventDefault();
+      const f = form.elements;
+      const body = { topicName: f.topicName.value.trim() };
+      if (f.partitions.value) body.partitions = Number(f.partitions.value);
+      if (f.replicationFactor.value) body.replicationFactor = Number(f.replicationFactor.value);
+      const configs = {};
+      for (const line of f.configs.value.split("\n")) {
+        const i = line.indexOf("=");
+        if (i > 0) configs[line.slice(0, i).trim()] = line.slice(i + 1).trim();
+      }
+      if (Object.keys(configs).length) body.configs = configs;
+      const catalog = {};
+      for (const k of ["owner", "contact", "domain", "classification", "description", "retentionRationale"]) {
+        if (f[k].value.trim()) catalog[k] = f[k].value.trim();
+      }
+      if (Object.keys(catalog).length) body.catalog = catalog;
+
+      try {
+        showError(null);
+        const created = await api("POST", "/topics", body);
+        location.hash = "#/topics/" + encodeURIComponent(created.name);
+      } catch (err) {
+        showError(err);
+      }
+    });
+    return [el("h2", {}, "Create topic"), form];
+  },
+};
+
+// render shows the view named by the location hash, e.g. #/topics/orders.prod.payments
+async function render() {
+  const [, view = "topics", arg] = location.hash.split("/").map(decodeURIComponent);
+  for (const a of document.querySelectorAll("nav a")) {
+    a.classList.toggle("active", a.getAttribute("href") === "#/" + view);
+  }
+  const main = $("#view");
+  main.replaceChildren(el("p", {}, "Loading…"));
+  showError(null);
+  try {
+    const content = view === "topics" && arg ? await views.topic(arg) : await (views[view] || views.topics)();
+    main.replaceChildren(...content);
+  } catch (err) {
+    main.replaceChildren();
+    showError(err);
+  }
+}
+
+async function loadClusters() {
+  const select = $("#cluster");
+  const names = await api("GET", "/clusters");
+  if (!names.includes(state.cluster)) state.cluster = names.includes("default") ? "default" : names[0];
+  select.replaceChildren(...names.map((n) => el("option", n === state.cluster ? { selected: "" } : {}, n)));
+}
+
+$("#cluster").addEventListener("change", (e) => {
+  state.cluster = e.target.value;
+  localStorage.setItem("topicServiceCluster", state.cluster);
+  render();
+});
+
+$("#login").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  state.token = $("#token").value;
+  localStorage.setItem("topicServiceToken", state.token);
+  $("#login").hidden = true;
+  $("#logout").hidden = false;
+  await start();
+});
+
+$("#logout").addEventListener("click", () => {
+  localStorage.removeItem("topicServiceToken");
+  state.token = "";
+  location.reload();
+});
+
+async function start() {
+  try {
+    await loadClusters();
+  } catch (err) {
+    return showError(err);
+  }
+  $("#logout").hidden = !state.token;
+  render();
+}
+
+window.addEventListener("hashchange", render);
+start();
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SignatureHeader carries "sha256=<hex HMAC of timestamp.body>" computed with the webhook secret,
+// where timestamp is the value of TimestampHeader. Receivers should recompute it and reject
+// deliveries whose timestamp is further than a few minutes from their clock (see VerifyPayload),
+// so a captured delivery cannot be replayed later.
+const SignatureHeader = "X-Kafka-Admin-Signature"
+
+// TimestampHeader carries the Unix time in seconds at which a delivery attempt was signed.
+const TimestampHeader = "X-Kafka-Admin-Timestamp"
+
+// DefaultSignatureTolerance is how far a delivery timestamp may be from the receiver's clock.
+const DefaultSignatureTolerance = 5 * time.Minute
+
+// WebhookConfig is one receiver in the -webhooks YAML file.
+type WebhookConfig struct {
+	URL    string      `yaml:"url"`
+	Secret string      `yaml:"secret"`
+	Events []EventType `yaml:"events"` // empty means every event
+}
+
+// wants reports whether the webhook subscribed to the event type.
+func (h WebhookConfig) wants(t EventType) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// loadWebhooks reads the webhook receivers from a YAML file with a top-level "webhooks" list.
+func loadWebhooks(file string) ([]WebhookConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var wf struct {
+		Webhooks []WebhookConfig `yaml:"webhooks"`
+	}
+	if err := yaml.Unmarshal(data, &wf); err != nil {
+		return nil, err
+	}
+	return wf.Webhooks, nil
+}
+
+// WebhookDispatcher delivers events to webhooks, retrying with exponential backoff and writing
+// undeliverable events to a dead-letter file. Every hook has its own queue and worker, so a
+// failing receiver only delays its own deliveries.
+type WebhookDispatcher struct {
+	Hooks          []WebhookConfig
+	Client         *http.Client
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	DeadLetterFile string
+
+	queues []chan Event // one per hook
+	mu     sync.RWMutex // guards closed against Enqueue sending on a closed queue
+	closed bool
+	ctx    context.Context // cancelled when Shutdown stops waiting; aborts requests and backoff
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	dlMu   sync.Mutex
+}
+
+// NewWebhookDispatcher returns a dispatcher with default retry settings. Call Start before use.
+func NewWebhookDispatcher(hooks []WebhookConfig, deadLetterFile string) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		Hooks:          hooks,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		DeadLetterFile: deadLetterFile,
+		queues:         make([]chan Event, len(hooks)),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan Event, 256)
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	return d
+}
+
+// Start launches one delivery worker per webhook.
+func (d *WebhookDispatcher) Start() {
+	for i, hook := range d.Hooks {
+		d.wg.Add(1)
+		go func(hook WebhookConfig, queue chan Event) {
+			defer d.wg.Done()
+			for e := range queue {
+				d.deliver(hook, e)
+			}
+		}(hook, d.queues[i])
+	}
+}
+
+// Enqueue queues an event for every webhook that wants it without blocking. The event is
+// dead-lettered for a webhook whose queue is full, and for all of them after Shutdown.
+// It has the signature of an EventBus subscriber.
+func (d *WebhookDispatcher) Enqueue(e Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		d.deadLetter("", e, fmt.Errorf("dispatcher is shut down"))
+		return
+	}
+	for i, hook := range d.Hooks {
+		if !hook.wants(e.Type) {
+			continue
+		}
+		select {
+		case d.queues[i] <- e:
+		default:
+			d.deadLetter(hook.URL, e, fmt.Errorf("delivery queue full"))
+		}
+	}
+}
+
+// Close stops accepting events and waits for queued deliveries to finish.
+func (d *WebhookDispatcher) Close() {
+	d.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting events and waits for queued deliveries until ctx is done. Deliveries
+// still pending then are aborted and dead-lettered.
+func (d *WebhookDispatcher) Shutdown(ctx context.Context) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	for _, q := range d.queues {
+		close(q)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Print("Webhook dispatcher shutting down, dead-lettering undelivered events")
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+}
+
+// deliver posts the event to one webhook until it gets a 2xx or runs out of attempts
+func (d *WebhookDispatcher) deliver(hook WebhookConfig, e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		d.deadLetter(hook.URL, e, err)
+		return
+	}
+
+	backoff := d.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		if d.ctx.Err() != nil {
+			if err == nil {
+				err = fmt.Errorf("dispatcher shut down before delivery")
+			} else {
+				err = fmt.Errorf("dispatcher shut down after %d attempts: %w", attempt-1, err)
+			}
+			break
+		}
+		err = d.post(hook, e, body)
+		if err == nil {
+			return
+		}
+		if attempt >= d.MaxAttempts {
+			break
+		}
+		log.Printf("Webhook %s attempt %d for event %s failed: %v", hook.URL, attempt, e.ID, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-d.ctx.Done():
+			timer.Stop()
+		}
+		backoff *= 2
+	}
+	d.deadLetter(hook.URL, e, err)
+}
+
+func (d *WebhookDispatcher) post(hook WebhookConfig, e Event, body []byte) error {
+	req, err := http.NewRequestWithContext(d.ctx, "POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Kafka-Admin-Event", string(e.Type))
+	req.Header.Set("X-Kafka-Admin-Delivery", e.ID)
+	if hook.Secret != "" {
+		// every attempt is signed afresh, so retries carry a current timestamp
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, SignPayload(hook.Secret, timestamp, body))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return nil
+}
+
+// deadLetter appends an undeliverable event as a JSON line to the dead-letter file
+func (d *WebhookDispatcher) deadLetter(url string, e Event, cause error) {
+	log.Printf("Webhook %s gave up on event %s: %v", url, e.ID, cause)
+	if d.DeadLetterFile == "" {
+		return
+	}
+	line, _ := json.Marshal(struct {
+		URL    string    `json:"url,omitempty"`
+		Error  string    `json:"error"`
+		Failed time.Time `json:"failedAt"`
+		Event  Event     `json:"event"`
+	}{url, cause.Error(), time.Now().UTC(), e})
+
+	d.dlMu.Lock()
+	defer d.dlMu.Unlock()
+	f, err := os.OpenFile(d.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("Failed to open dead-letter file: %v", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// SignPayload returns the signature header value for a delivery:
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPayload is the check a receiver runs on a delivery: the signature must match and the
+// timestamp must be within tolerance of now, which bounds how long a captured delivery can be replayed.
+func VerifyPayload(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	timestamp := header.Get(TimestampHeader)
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid %s", TimestampHeader)
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+		return fmt.Errorf("timestamp %s is outside the %s tolerance", timestamp, tolerance)
+	}
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(SignPayload(secret, timestamp, body))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
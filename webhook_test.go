@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// receiver is an httptest webhook endpoint that records verified deliveries
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	bodies   [][]byte
+	attempts int32
+	failFor  int32 // answer 500 to this many requests before succeeding
+	block    chan struct{}
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	rc := &receiver{}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// read the body first so the server notices when the dispatcher gives up on the request
+		body, _ := io.ReadAll(r.Body)
+		if rc.block != nil {
+			select {
+			case <-rc.block:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if secret != "" {
+			if err := VerifyPayload(secret, r.Header, body, DefaultSignatureTolerance, time.Now()); err != nil {
+				t.Errorf("delivery rejected: %v", err)
+			}
+		}
+		if atomic.AddInt32(&rc.attempts, 1) <= rc.failFor {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rc.mu.Lock()
+		rc.bodies = append(rc.bodies, body)
+		rc.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) delivered() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.bodies)
+}
+
+func (rc *receiver) body(i int) []byte {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.bodies[i]
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func readDeadLetters(t *testing.T, file string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("dead-letter line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func newTestDispatcher(t *testing.T, hooks ...WebhookConfig) *WebhookDispatcher {
+	d := NewWebhookDispatcher(hooks, filepath.Join(t.TempDir(), "dead-letter.jsonl"))
+	d.InitialBackoff = time.Millisecond
+	d.MaxAttempts = 3
+	d.Start()
+	return d
+}
+
+func TestWebhookSignedDelivery(t *testing.T) {
+	rc := newReceiver(t, "s3cret")
+	d := newTestDispatcher(t, WebhookConfig{URL: rc.URL, Secret: "s3cret", Events: []EventType{EventTopicCreated}})
+
+	d.Enqueue(Event{ID: "1", Type: EventTopicCreated, Cluster: "dev", Data: map[string]string{"topic": "orders"}})
+	d.Enqueue(Event{ID: "2", Type: EventTopicDeleted, Cluster: "dev"}) // not subscribed
+	d.Close()
+
+	if rc.delivered() != 1 {
+		t.Fatalf("delivered %d events, want 1", rc.delivered())
+	}
+	var e Event
+	if err := json.Unmarshal(rc.body(0), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != "1" || e.Type != EventTopicCreated || e.Cluster != "dev" {
+		t.Errorf("delivered %+v", e)
+	}
+}
+
+func TestVerifyPayloadRejectsReplay(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	signed := time.Unix(1700000000, 0)
+	header := http.Header{}
+	header.Set(TimestampHeader, "1700000000")
+	header.Set(SignatureHeader, SignPayload("s3cret", "1700000000", body))
+
+	if err := VerifyPayload("s3cret", header, body, time.Minute, signed.Add(30*time.Second)); err != nil {
+		t.Errorf("fresh delivery rejected: %v", err)
+	}
+	if err := VerifyPayload("s3cret", header, body, time.Minute, signed.Add(time.Hour)); err == nil {
+		t.Error("replayed delivery accepted")
+	}
+	if err := VerifyPayload("other", header, body, time.Minute, signed); err == nil {
+		t.Error("wrong secret accepted")
+	}
+	// moving the timestamp forward invalidates the signature
+	header.Set(TimestampHeader, "1700003600")
+	if err := VerifyPayload("s3cret", header, body, time.Minute, signed.Add(time.Hour)); err == nil {
+		t.Error("re-stamped delivery accepted")
+	}
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	rc := newReceiver(t, "")
+	rc.failFor = 2
+	d := newTestDispatcher(t, WebhookConfig{URL: rc.URL})
+
+	d.Enqueue(Event{ID: "1", Type: EventACLChanged})
+	d.Close()
+
+	if attempts := atomic.LoadInt32(&rc.attempts); attempts != 3 || rc.delivered() != 1 {
+		t.Errorf("attempts = %d, delivered = %d; want 3 and 1", attempts, rc.delivered())
+	}
+	if dl := readDeadLetters(t, d.DeadLetterFile); len(dl) != 0 {
+		t.Errorf("dead letters = %v, want none", dl)
+	}
+}
+
+func TestWebhookDeadLetterAfterMaxAttempts(t *testing.T) {
+	rc := newReceiver(t, "")
+	rc.failFor = 100
+	d := newTestDispatcher(t, WebhookConfig{URL: rc.URL})
+
+	d.Enqueue(Event{ID: "1", Type: EventTopicDeleted})
+	d.Close()
+
+	if attempts := atomic.LoadInt32(&rc.attempts); attempts != int32(d.MaxAttempts) {
+		t.Errorf("attempts = %d, want %d", attempts, d.MaxAttempts)
+	}
+	dl := readDeadLetters(t, d.DeadLetterFile)
+	if len(dl) != 1 {
+		t.Fatalf("dead letters = %v, want 1", dl)
+	}
+	if dl[0]["url"] != rc.URL || dl[0]["event"].(map[string]interface{})["id"] != "1" {
+		t.Errorf("dead letter = %v", dl[0])
+	}
+}
+
+func TestWebhookSlowHookDoesNotBlockOthers(t *testing.T) {
+	slow := newReceiver(t, "")
+	slow.block = make(chan struct{})
+	fast := newReceiver(t, "")
+	d := newTestDispatcher(t, WebhookConfig{URL: slow.URL}, WebhookConfig{URL: fast.URL})
+
+	for i := 0; i < 3; i++ {
+		d.Enqueue(Event{Type: EventTopicCreated})
+	}
+	waitFor(t, "fast hook deliveries", func() bool { return fast.delivered() == 3 })
+
+	close(slow.block)
+	d.Close()
+	if slow.delivered() != 3 {
+		t.Errorf("slow hook delivered %d events, want 3", slow.delivered())
+	}
+}
+
+func TestWebhookEnqueueAfterShutdown(t *testing.T) {
+	rc := newReceiver(t, "")
+	d := newTestDispatcher(t, WebhookConfig{URL: rc.URL})
+	d.Close()
+	d.Close() // idempotent
+
+	d.Enqueue(Event{ID: "late", Type: EventTopicCreated})
+	if rc.delivered() != 0 {
+		t.Errorf("delivered %d events after shutdown", rc.delivered())
+	}
+	if dl := readDeadLetters(t, d.DeadLetterFile); len(dl) != 1 || dl[0]["error"] != "dispatcher is shut down" {
+		t.Errorf("dead letters = %v", dl)
+	}
+}
+
+func TestWebhookShutdownDeadlineAbortsDelivery(t *testing.T) {
+	rc := newReceiver(t, "")
+	rc.block = make(chan struct{}) // no answer until the test is over
+	t.Cleanup(func() { close(rc.block) })
+	d := newTestDispatcher(t, WebhookConfig{URL: rc.URL})
+	d.Enqueue(Event{ID: "stuck", Type: EventTopicCreated})
+	d.Enqueue(Event{ID: "queued", Type: EventTopicCreated})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	d.Shutdown(ctx)
+
+	dl := readDeadLetters(t, d.DeadLetterFile)
+	if len(dl) != 2 {
+		t.Fatalf("dead letters = %v, want both events", dl)
+	}
+}